@@ -0,0 +1,195 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/thewerther/webserver/internal/database"
+)
+
+type FollowResponse struct {
+	UserID     uuid.UUID `json:"user_id"`
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+type FollowPageResponse struct {
+	Users      []FollowResponse `json:"users"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+func (cfg *ApiConfig) followUser(w http.ResponseWriter, req *http.Request) {
+	userExists, err := authenticate(req, cfg, scopeFollowsWrite)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	followee, err := cfg.getUserFromPath(req)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "User does not exist", err)
+		return
+	}
+
+	if followee.ID == userExists.ID {
+		respondWithError(w, http.StatusBadRequest, "Cannot follow yourself", nil)
+		return
+	}
+
+	// following twice is not an error
+	_, err = cfg.Database.FollowUser(req.Context(), database.FollowUserParams{
+		FollowerID: userExists.ID,
+		FolloweeID: followee.ID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error following user in database", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusNoContent, struct{}{})
+}
+
+func (cfg *ApiConfig) unfollowUser(w http.ResponseWriter, req *http.Request) {
+	userExists, err := authenticate(req, cfg, scopeFollowsWrite)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	followeeID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Error getting user id from request", err)
+		return
+	}
+
+	numDeleted, err := cfg.Database.UnfollowUser(req.Context(), database.UnfollowUserParams{
+		FollowerID: userExists.ID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error unfollowing user in database", err)
+		return
+	}
+
+	if numDeleted == 0 {
+		respondWithError(w, http.StatusNotFound, "Not following user", nil)
+		return
+	}
+
+	respondWithJSON(w, http.StatusNoContent, struct{}{})
+}
+
+// getFollowers lists who follows a user, most recent follower first.
+func (cfg *ApiConfig) getFollowers(w http.ResponseWriter, req *http.Request) {
+	user, params, ok := cfg.parseFollowListRequest(w, req)
+	if !ok {
+		return
+	}
+
+	rows, err := cfg.Database.GetFollowers(req.Context(), database.GetFollowersParams{
+		UserID:          user.ID,
+		CursorCreatedAt: params.CursorCreatedAt,
+		CursorID:        params.CursorID,
+		PageLimit:       params.Limit + 1,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error querying followers from database", err)
+		return
+	}
+
+	follows := []FollowResponse{}
+	for _, row := range rows {
+		follows = append(follows, FollowResponse{UserID: row.UserID, FollowedAt: row.CreatedAt})
+	}
+	respondWithJSON(w, http.StatusOK, newFollowPage(follows, params.Limit))
+}
+
+// getFollowing lists who a user follows, most recently followed first.
+func (cfg *ApiConfig) getFollowing(w http.ResponseWriter, req *http.Request) {
+	user, params, ok := cfg.parseFollowListRequest(w, req)
+	if !ok {
+		return
+	}
+
+	rows, err := cfg.Database.GetFollowing(req.Context(), database.GetFollowingParams{
+		UserID:          user.ID,
+		CursorCreatedAt: params.CursorCreatedAt,
+		CursorID:        params.CursorID,
+		PageLimit:       params.Limit + 1,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error querying followed users from database", err)
+		return
+	}
+
+	follows := []FollowResponse{}
+	for _, row := range rows {
+		follows = append(follows, FollowResponse{UserID: row.UserID, FollowedAt: row.CreatedAt})
+	}
+	respondWithJSON(w, http.StatusOK, newFollowPage(follows, params.Limit))
+}
+
+// getTimeline returns the chirps of everybody the user follows, newest first.
+func (cfg *ApiConfig) getTimeline(w http.ResponseWriter, req *http.Request) {
+	userExists, err := authenticate(req, cfg, scopeChirpsRead)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	params, err := parsePageParams(req.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid query parameters", err)
+		return
+	}
+
+	dbChirps, err := cfg.Database.GetTimelinePage(req.Context(), database.GetTimelinePageParams{
+		FollowerID:      userExists.ID,
+		CursorCreatedAt: params.CursorCreatedAt,
+		CursorID:        params.CursorID,
+		PageLimit:       params.Limit + 1,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error querying timeline from database", err)
+		return
+	}
+
+	cfg.respondWithChirpPage(w, req, dbChirps, params.Limit)
+}
+
+func (cfg *ApiConfig) getUserFromPath(req *http.Request) (database.User, error) {
+	id, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		return database.User{}, err
+	}
+
+	return cfg.Database.GetUserById(req.Context(), id)
+}
+
+func (cfg *ApiConfig) parseFollowListRequest(w http.ResponseWriter, req *http.Request) (database.User, pageParams, bool) {
+	user, err := cfg.getUserFromPath(req)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "User does not exist", err)
+		return database.User{}, pageParams{}, false
+	}
+
+	params, err := parsePageParams(req.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid query parameters", err)
+		return database.User{}, pageParams{}, false
+	}
+
+	return user, params, true
+}
+
+// newFollowPage trims a result fetched with limit+1 rows like newChirpPage.
+func newFollowPage(follows []FollowResponse, limit int32) FollowPageResponse {
+	page := FollowPageResponse{Users: follows}
+	if len(follows) > int(limit) {
+		page.Users = follows[:limit]
+		last := page.Users[len(page.Users)-1]
+		page.NextCursor = encodeCursor(pageCursor{CreatedAt: last.FollowedAt, ID: last.UserID})
+	}
+
+	return page
+}
@@ -3,13 +3,14 @@ package main
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"reflect"
 
+	"github.com/google/uuid"
 	"github.com/thewerther/webserver/internal/auth"
 	"github.com/thewerther/webserver/internal/database"
-	"golang.org/x/crypto/bcrypt"
 )
 
 func respondWithError(w http.ResponseWriter, code int, msg string, err error) {
@@ -27,6 +28,16 @@ func respondWithError(w http.ResponseWriter, code int, msg string, err error) {
 	})
 }
 
+// respondWithAuthError responds to a request authenticate rejected.
+func respondWithAuthError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errInsufficientScope) {
+		respondWithError(w, http.StatusForbidden, "Insufficient scope", err)
+		return
+	}
+
+	respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+}
+
 func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	dat, err := json.Marshal(payload)
@@ -49,13 +60,58 @@ func decodeRequestBody(dataStruct any, req *http.Request) error {
   return nil
 }
 
-func authenticate(req *http.Request, cfg *ApiConfig) (database.User, error) {
-  accessToken, err := auth.GetBearerToken(req.Header)
+// parseAccessToken validates the access token of the request and checks it
+// has not been revoked.
+func parseAccessToken(req *http.Request, cfg *ApiConfig) (*auth.Claims, error) {
+	accessToken, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := auth.ParseJWT(accessToken, cfg.JWTKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Revocations.IsRevoked(claims) {
+		return nil, errors.New("Access token has been revoked")
+	}
+
+	return claims, nil
+}
+
+var errInsufficientScope = errors.New("Token is missing the required scope")
+
+// authenticate returns the user of the access token or personal access token
+// of the request. Personal access tokens have to carry scope, access tokens of
+// a login are allowed everything.
+func authenticate(req *http.Request, cfg *ApiConfig, scope string) (database.User, error) {
+	token, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		return database.User{}, err
+	}
+
+	if !auth.IsPersonalAccessToken(token) {
+		return authenticateSession(req, cfg)
+	}
+
+	personalToken, err := cfg.usePersonalAccessToken(req.Context(), token, scope)
+	if err != nil {
+		return database.User{}, err
+	}
+
+	return cfg.Database.GetUserById(req.Context(), personalToken.UserID)
+}
+
+// authenticateSession only accepts access tokens of a login, the account
+// itself can not be managed with personal access tokens.
+func authenticateSession(req *http.Request, cfg *ApiConfig) (database.User, error) {
+  claims, err := parseAccessToken(req, cfg)
   if err != nil {
     return database.User{}, err
   }
 
-  userIdFromToken, err := auth.ValidateJWT(accessToken, cfg.JWT_Secret)
+  userIdFromToken, err := claims.UserID()
   if err != nil {
     return database.User{}, err
   }
@@ -72,6 +128,32 @@ func authenticate(req *http.Request, cfg *ApiConfig) (database.User, error) {
   return userExists, nil
 }
 
+// optionalViewerID returns the user of the access token if the request has a
+// valid one. Public endpoints use it to personalise responses, so a missing or
+// invalid token is not an error.
+func optionalViewerID(req *http.Request, cfg *ApiConfig) uuid.NullUUID {
+	token, err := auth.GetBearerToken(req.Header)
+	if err == nil && auth.IsPersonalAccessToken(token) {
+		personalToken, err := cfg.usePersonalAccessToken(req.Context(), token, scopeChirpsRead)
+		if err != nil {
+			return uuid.NullUUID{}
+		}
+		return uuid.NullUUID{UUID: personalToken.UserID, Valid: true}
+	}
+
+	claims, err := parseAccessToken(req, cfg)
+	if err != nil {
+		return uuid.NullUUID{}
+	}
+
+	userID, err := claims.UserID()
+	if err != nil {
+		return uuid.NullUUID{}
+	}
+
+	return uuid.NullUUID{UUID: userID, Valid: true}
+}
+
 func authorize(req *http.Request, cfg *ApiConfig) (database.User, error, int) {
 	loginReq := LoginRequest{}
 	err := decodeRequestBody(&loginReq, req)
@@ -88,10 +170,17 @@ func authorize(req *http.Request, cfg *ApiConfig) (database.User, error, int) {
     return database.User{}, errors.New("User does not exist"), http.StatusBadRequest
 	}
 
-	err = bcrypt.CompareHashAndPassword([]byte(userExists.HashedPassword), []byte(loginReq.Password))
+	match, needsRehash, err := cfg.PasswordHasher.Verify(loginReq.Password, userExists.HashedPassword)
 	if err != nil {
+		return database.User{}, fmt.Errorf("Error verifying password: %w", err), http.StatusInternalServerError
+	}
+	if !match {
 		return database.User{}, errors.New("Incorrect email or password"), http.StatusUnauthorized
 	}
 
+	if needsRehash {
+		cfg.rehashPassword(req, userExists, loginReq.Password)
+	}
+
   return userExists, nil, 0
 }
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/thewerther/webserver/internal/database"
+	"github.com/thewerther/webserver/internal/entities"
+)
+
+const (
+	defaultTrendingWindow = 24 * time.Hour
+	maxTrendingWindow     = 7 * 24 * time.Hour
+)
+
+// MentionEntity points at the part of a chirp body that mentions a user,
+// Start and End are character offsets into the body, End is exclusive.
+type MentionEntity struct {
+	UserID uuid.UUID `json:"user_id"`
+	Handle string    `json:"handle"`
+	Start  int32     `json:"start"`
+	End    int32     `json:"end"`
+}
+
+type TrendingHashtag struct {
+	Tag        string    `json:"tag"`
+	ChirpCount int64     `json:"chirp_count"`
+	LastUsedAt time.Time `json:"last_used_at"`
+}
+
+type TrendingHashtagsResponse struct {
+	Window   string            `json:"window"`
+	Hashtags []TrendingHashtag `json:"hashtags"`
+}
+
+// storeChirpEntities replaces the hashtags and mentions stored for a chirp
+// with the ones found in body. Failing to do so must not fail the request
+// since the chirp has already been stored.
+func (cfg *ApiConfig) storeChirpEntities(ctx context.Context, chirpID uuid.UUID, body string) {
+	found := entities.Extract(body)
+
+	err := cfg.Database.SetChirpHashtags(ctx, database.SetChirpHashtagsParams{
+		ChirpID: chirpID,
+		Tags:    found.Tags(),
+	})
+	if err != nil {
+		log.Printf("Error storing hashtags of chirp %v: %v", chirpID, err)
+	}
+
+	params := database.SetChirpMentionsParams{ChirpID: chirpID}
+	for _, mention := range found.Mentions {
+		params.Names = append(params.Names, mention.Name)
+		params.StartIndexes = append(params.StartIndexes, int32(mention.Start))
+		params.EndIndexes = append(params.EndIndexes, int32(mention.End))
+	}
+
+	err = cfg.Database.SetChirpMentions(ctx, params)
+	if err != nil {
+		log.Printf("Error storing mentions of chirp %v: %v", chirpID, err)
+	}
+}
+
+// addChirpMentions loads the mentions of a whole page of chirps at once.
+func (cfg *ApiConfig) addChirpMentions(ctx context.Context, chirps []ChirpResponse) error {
+	chirpIDs := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		chirpIDs = append(chirpIDs, chirp.ID)
+	}
+
+	dbMentions, err := cfg.Database.GetChirpMentions(ctx, chirpIDs)
+	if err != nil {
+		return err
+	}
+
+	mentionsByChirp := map[uuid.UUID][]MentionEntity{}
+	for _, mention := range dbMentions {
+		mentionsByChirp[mention.ChirpID] = append(mentionsByChirp[mention.ChirpID], MentionEntity{
+			UserID: mention.UserID,
+			Handle: mention.Handle,
+			Start:  mention.StartIndex,
+			End:    mention.EndIndex,
+		})
+	}
+
+	for idx := range chirps {
+		chirps[idx].Mentions = mentionsByChirp[chirps[idx].ID]
+		if chirps[idx].Mentions == nil {
+			chirps[idx].Mentions = []MentionEntity{}
+		}
+	}
+
+	return nil
+}
+
+func (cfg *ApiConfig) getHashtagChirps(w http.ResponseWriter, req *http.Request) {
+	tag, ok := entities.NormalizeTag(req.PathValue("tag"))
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Invalid hashtag", errors.New("Hashtags can only contain letters, digits and underscores"))
+		return
+	}
+
+	params, err := parsePageParams(req.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid query parameters", err)
+		return
+	}
+
+	dbChirps, err := cfg.Database.GetHashtagChirpsPage(req.Context(), database.GetHashtagChirpsPageParams{
+		Tag:             tag,
+		CursorCreatedAt: params.CursorCreatedAt,
+		CursorID:        params.CursorID,
+		PageLimit:       params.Limit + 1,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error querying chirps by hashtag from database", err)
+		return
+	}
+
+	cfg.respondWithChirpPage(w, req, dbChirps, params.Limit)
+}
+
+// getTrendingHashtags ranks hashtags by the number of chirps using them in
+// the last `window` (a Go duration like "6h"), defaulting to a day.
+func (cfg *ApiConfig) getTrendingHashtags(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+
+	limit, err := parsePageLimit(query)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid query parameters", err)
+		return
+	}
+
+	window := defaultTrendingWindow
+	if windowParam := query.Get("window"); windowParam != "" {
+		window, err = time.ParseDuration(windowParam)
+		if err != nil || window <= 0 || window > maxTrendingWindow {
+			respondWithError(w, http.StatusBadRequest, "Invalid query parameters", errors.New("window has to be a positive duration of at most 168h"))
+			return
+		}
+	}
+
+	dbHashtags, err := cfg.Database.GetTrendingHashtags(req.Context(), database.GetTrendingHashtagsParams{
+		CreatedAfter: time.Now().UTC().Add(-window),
+		PageLimit:    limit,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error querying trending hashtags from database", err)
+		return
+	}
+
+	response := TrendingHashtagsResponse{
+		Window:   window.String(),
+		Hashtags: []TrendingHashtag{},
+	}
+	for _, hashtag := range dbHashtags {
+		response.Hashtags = append(response.Hashtags, TrendingHashtag{
+			Tag:        hashtag.Tag,
+			ChirpCount: hashtag.ChirpCount,
+			LastUsedAt: hashtag.LastUsedAt,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
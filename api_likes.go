@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/thewerther/webserver/internal/database"
+)
+
+func (cfg *ApiConfig) likeChirp(w http.ResponseWriter, req *http.Request) {
+	userExists, err := authenticate(req, cfg, scopeChirpsWrite)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	chirpID := req.PathValue("chirpID")
+	id, err := uuid.Parse(chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Error getting chirp id from request", err)
+		return
+	}
+
+	_, err = cfg.Database.GetChirpByID(req.Context(), id)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Error querying chirp by id", err)
+		return
+	}
+
+	// the primary key keeps a user from liking twice, doing so is not an error
+	_, err = cfg.Database.LikeChirp(req.Context(), database.LikeChirpParams{
+		ChirpID: id,
+		UserID:  userExists.ID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error liking chirp in database", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusNoContent, struct{}{})
+}
+
+func (cfg *ApiConfig) unlikeChirp(w http.ResponseWriter, req *http.Request) {
+	userExists, err := authenticate(req, cfg, scopeChirpsWrite)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	chirpID := req.PathValue("chirpID")
+	id, err := uuid.Parse(chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Error getting chirp id from request", err)
+		return
+	}
+
+	numDeleted, err := cfg.Database.UnlikeChirp(req.Context(), database.UnlikeChirpParams{
+		ChirpID: id,
+		UserID:  userExists.ID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error unliking chirp in database", err)
+		return
+	}
+
+	if numDeleted == 0 {
+		respondWithError(w, http.StatusNotFound, "Chirp is not liked", nil)
+		return
+	}
+
+	respondWithJSON(w, http.StatusNoContent, struct{}{})
+}
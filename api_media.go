@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/thewerther/webserver/internal/database"
+	"github.com/thewerther/webserver/internal/media"
+)
+
+const (
+	maxMediaPerChirp = 4
+	// uploads that are not attached to a chirp within this time are purged
+	unattachedMediaRetention = 24 * time.Hour
+)
+
+var errMediaNotAttachable = errors.New("media_ids have to be your own uploads that are not attached to a chirp yet")
+
+type MediaResponse struct {
+	ID           uuid.UUID `json:"id"`
+	ContentType  string    `json:"content_type"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+	Width        int32     `json:"width"`
+	Height       int32     `json:"height"`
+}
+
+func mediaToResponse(dbMedia database.Medium) MediaResponse {
+	return MediaResponse{
+		ID:           dbMedia.ID,
+		ContentType:  dbMedia.ContentType,
+		URL:          "/api/media/" + dbMedia.ID.String(),
+		ThumbnailURL: "/api/media/" + dbMedia.ID.String() + "/thumbnail",
+		Width:        dbMedia.Width,
+		Height:       dbMedia.Height,
+	}
+}
+
+// uploadMedia stores the image in the `file` field of a multipart form. The
+// upload can be attached to a chirp by passing its id in `media_ids`.
+func (cfg *ApiConfig) uploadMedia(w http.ResponseWriter, req *http.Request) {
+	userExists, err := authenticate(req, cfg, scopeChirpsWrite)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	// leave some room for the rest of the multipart body
+	req.Body = http.MaxBytesReader(w, req.Body, cfg.MaxUploadBytes+1<<20)
+	file, _, err := req.FormFile("file")
+	if err != nil {
+		maxBytesErr := &http.MaxBytesError{}
+		if errors.As(err, &maxBytesErr) {
+			respondWithError(w, http.StatusRequestEntityTooLarge, "Upload is too large", err)
+			return
+		}
+		respondWithError(w, http.StatusBadRequest, "Expected an image in the file field of a multipart form", err)
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, cfg.MaxUploadBytes+1))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Error reading upload", err)
+		return
+	}
+	if int64(len(data)) > cfg.MaxUploadBytes {
+		respondWithError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Images can be at most %d bytes", cfg.MaxUploadBytes), nil)
+		return
+	}
+
+	img, err := media.ProcessImage(data)
+	if errors.Is(err, media.ErrUnsupportedType) {
+		respondWithError(w, http.StatusUnsupportedMediaType, err.Error(), err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid image", err)
+		return
+	}
+
+	id := uuid.New()
+	storageKey := id.String() + img.Extension
+	thumbnailKey := id.String() + "_thumbnail" + img.ThumbnailExtension
+
+	err = cfg.Media.Put(req.Context(), storageKey, bytes.NewReader(img.Data))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error storing image", err)
+		return
+	}
+	err = cfg.Media.Put(req.Context(), thumbnailKey, bytes.NewReader(img.Thumbnail))
+	if err != nil {
+		cfg.deleteMediaFiles(req.Context(), storageKey)
+		respondWithError(w, http.StatusInternalServerError, "Error storing thumbnail", err)
+		return
+	}
+
+	dbMedia, err := cfg.Database.CreateMedia(req.Context(), database.CreateMediaParams{
+		ID:                   id,
+		UserID:               uuid.NullUUID{UUID: userExists.ID, Valid: true},
+		ContentType:          img.ContentType,
+		StorageKey:           storageKey,
+		ThumbnailContentType: img.ThumbnailContentType,
+		ThumbnailKey:         thumbnailKey,
+		Width:                int32(img.Width),
+		Height:               int32(img.Height),
+		SizeBytes:            int64(len(img.Data)),
+		CreatedAt:            time.Now().UTC(),
+	})
+	if err != nil {
+		// without a row the purge would never find the files
+		cfg.deleteMediaFiles(req.Context(), storageKey, thumbnailKey)
+		respondWithError(w, http.StatusInternalServerError, "Error creating media in database", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, mediaToResponse(dbMedia))
+}
+
+func (cfg *ApiConfig) getMedia(w http.ResponseWriter, req *http.Request) {
+	cfg.serveMedia(w, req, false)
+}
+
+func (cfg *ApiConfig) getMediaThumbnail(w http.ResponseWriter, req *http.Request) {
+	cfg.serveMedia(w, req, true)
+}
+
+func (cfg *ApiConfig) serveMedia(w http.ResponseWriter, req *http.Request, thumbnail bool) {
+	id, err := uuid.Parse(req.PathValue("mediaID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Error getting media id from request", err)
+		return
+	}
+
+	dbMedia, err := cfg.Database.GetVisibleMediaByID(req.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Media does not exist", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error querying media from database", err)
+		return
+	}
+
+	key, contentType := dbMedia.StorageKey, dbMedia.ContentType
+	if thumbnail {
+		key, contentType = dbMedia.ThumbnailKey, dbMedia.ThumbnailContentType
+	}
+
+	file, err := cfg.Media.Open(req.Context(), key)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error reading media from storage", err)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.WriteHeader(http.StatusOK)
+	_, err = io.Copy(w, file)
+	if err != nil {
+		log.Printf("Error sending media %v: %v", id, err)
+	}
+}
+
+// validateMediaIDs checks that all uploads in mediaIDs belong to the user and
+// are not attached to another chirp yet.
+func (cfg *ApiConfig) validateMediaIDs(ctx context.Context, userID uuid.UUID, mediaIDs []uuid.UUID) error {
+	if len(mediaIDs) > maxMediaPerChirp {
+		return fmt.Errorf("Chirps can have at most %d images", maxMediaPerChirp)
+	}
+
+	seen := map[uuid.UUID]struct{}{}
+	for _, id := range mediaIDs {
+		if _, exists := seen[id]; exists {
+			return errors.New("media_ids contains duplicates")
+		}
+		seen[id] = struct{}{}
+	}
+
+	count, err := cfg.Database.CountAttachableMedia(ctx, database.CountAttachableMediaParams{
+		MediaIds: mediaIDs,
+		UserID:   userID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if count != int64(len(mediaIDs)) {
+		return errMediaNotAttachable
+	}
+
+	return nil
+}
+
+// attachMedia attaches uploads to a chirp that is being stored in the same
+// transaction, it fails unless every upload could be attached.
+func attachMedia(ctx context.Context, queries *database.Queries, chirp database.Chirp, mediaIDs []uuid.UUID) error {
+	if len(mediaIDs) == 0 {
+		return nil
+	}
+
+	numAttached, err := queries.AttachMediaToChirp(ctx, database.AttachMediaToChirpParams{
+		ChirpID:    uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		AttachedAt: time.Now().UTC(),
+		MediaIds:   mediaIDs,
+		UserID:     chirp.UserID,
+	})
+	if err != nil {
+		return err
+	}
+
+	// another chirp may have attached some of them since they were validated
+	if numAttached != int64(len(mediaIDs)) {
+		return errMediaNotAttachable
+	}
+
+	return nil
+}
+
+// addChirpMedia loads the media of a whole page of chirps at once.
+func (cfg *ApiConfig) addChirpMedia(ctx context.Context, chirps []ChirpResponse) error {
+	chirpIDs := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		chirpIDs = append(chirpIDs, chirp.ID)
+	}
+
+	dbMedia, err := cfg.Database.GetChirpMedia(ctx, chirpIDs)
+	if err != nil {
+		return err
+	}
+
+	mediaByChirp := map[uuid.UUID][]MediaResponse{}
+	for _, m := range dbMedia {
+		mediaByChirp[m.ChirpID.UUID] = append(mediaByChirp[m.ChirpID.UUID], mediaToResponse(m))
+	}
+
+	for idx := range chirps {
+		chirps[idx].Media = mediaByChirp[chirps[idx].ID]
+		if chirps[idx].Media == nil {
+			chirps[idx].Media = []MediaResponse{}
+		}
+	}
+
+	return nil
+}
+
+// purgeOrphanedMedia removes uploads that were never attached to a chirp or
+// whose chirp has been purged, both from the database and the storage.
+func (cfg *ApiConfig) purgeOrphanedMedia(ctx context.Context) {
+	purged, err := cfg.Database.PurgeOrphanedMedia(ctx, time.Now().UTC().Add(-unattachedMediaRetention))
+	if err != nil {
+		log.Printf("Error purging orphaned media: %v", err)
+		return
+	}
+
+	for _, m := range purged {
+		cfg.deleteMediaFiles(ctx, m.StorageKey, m.ThumbnailKey)
+	}
+
+	if len(purged) > 0 {
+		log.Printf("Purged %v orphaned media", len(purged))
+	}
+}
+
+// deleteMediaFiles removes files from the storage, failures are only logged.
+func (cfg *ApiConfig) deleteMediaFiles(ctx context.Context, keys ...string) {
+	for _, key := range keys {
+		err := cfg.Media.Delete(ctx, key)
+		if err != nil {
+			log.Printf("Error deleting %v from storage: %v", key, err)
+		}
+	}
+}
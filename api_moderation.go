@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/thewerther/webserver/internal/database"
+)
+
+type ReportRequest struct {
+	Reason string `json:"reason"`
+}
+
+type ReportedChirpResponse struct {
+	ID              uuid.UUID  `json:"id"`
+	Body            string     `json:"body"`
+	UserID          uuid.UUID  `json:"user_id"`
+	CreatedAt       time.Time  `json:"created_at"`
+	HiddenAt        *time.Time `json:"hidden_at"`
+	ReportCount     int64      `json:"report_count"`
+	FirstReportedAt time.Time  `json:"first_reported_at"`
+	Reasons         []string   `json:"reasons"`
+}
+
+const (
+	resolutionApproved = "approved"
+	resolutionHidden   = "hidden"
+	resolutionDeleted  = "deleted"
+)
+
+const maxReportReasonLength = 500
+
+func (cfg *ApiConfig) reportChirp(w http.ResponseWriter, req *http.Request) {
+	userExists, err := authenticate(req, cfg, scopeChirpsWrite)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	chirpID := req.PathValue("chirpID")
+	id, err := uuid.Parse(chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Error getting chirp id from request", err)
+		return
+	}
+
+	reportReq := ReportRequest{}
+	err = decodeRequestBody(&reportReq, req)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error decoding request", err)
+		return
+	}
+
+	if len(reportReq.Reason) > maxReportReasonLength {
+		respondWithError(w, http.StatusBadRequest, "Reason is too long", nil)
+		return
+	}
+
+	chirpExists, err := cfg.Database.GetChirpByID(req.Context(), id)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Error querying chirp by id", err)
+		return
+	}
+
+	if chirpExists.UserID == userExists.ID {
+		respondWithError(w, http.StatusBadRequest, "Cannot report your own chirp", nil)
+		return
+	}
+
+	numCreated, err := cfg.Database.CreateChirpReport(req.Context(), database.CreateChirpReportParams{
+		ChirpID:    id,
+		ReporterID: uuid.NullUUID{UUID: userExists.ID, Valid: true},
+		Reason:     reportReq.Reason,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating report in database", err)
+		return
+	}
+
+	if numCreated == 0 {
+		respondWithError(w, http.StatusConflict, "Chirp has already been reported", nil)
+		return
+	}
+
+	respondWithJSON(w, http.StatusAccepted, struct{}{})
+}
+
+// getModerationQueue lists chirps with unresolved reports, most reported first.
+func (cfg *ApiConfig) getModerationQueue(w http.ResponseWriter, req *http.Request) {
+	limit, err := parsePageLimit(req.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid limit", err)
+		return
+	}
+
+	dbReported, err := cfg.Database.GetOpenReportedChirps(req.Context(), limit)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error querying reported chirps from database", err)
+		return
+	}
+
+	reported := []ReportedChirpResponse{}
+	for _, chirp := range dbReported {
+		var hiddenAt *time.Time
+		if chirp.HiddenAt.Valid {
+			hiddenAt = &chirp.HiddenAt.Time
+		}
+
+		reported = append(reported, ReportedChirpResponse{
+			ID:              chirp.ID,
+			Body:            chirp.Body,
+			UserID:          chirp.UserID,
+			CreatedAt:       chirp.CreatedAt,
+			HiddenAt:        hiddenAt,
+			ReportCount:     chirp.ReportCount,
+			FirstReportedAt: chirp.FirstReportedAt,
+			Reasons:         chirp.Reasons,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, reported)
+}
+
+// approveChirp keeps a reported chirp, making it visible again if it was hidden.
+func (cfg *ApiConfig) approveChirp(w http.ResponseWriter, req *http.Request) {
+	cfg.moderateReportedChirp(w, req, resolutionApproved, (*database.Queries).UnhideChirpByID)
+}
+
+func (cfg *ApiConfig) hideChirp(w http.ResponseWriter, req *http.Request) {
+	cfg.moderateReportedChirp(w, req, resolutionHidden, func(queries *database.Queries, ctx context.Context, id uuid.UUID) (int64, error) {
+		return queries.HideChirpByID(ctx, database.HideChirpByIDParams{
+			HiddenAt: time.Now().UTC(),
+			ID:       id,
+		})
+	})
+}
+
+// deleteReportedChirp removes a chirp for good, unlike deleteChirpByID the
+// author cannot restore it.
+func (cfg *ApiConfig) deleteReportedChirp(w http.ResponseWriter, req *http.Request) {
+	cfg.moderateReportedChirp(w, req, resolutionDeleted, (*database.Queries).DeleteChirpByID)
+}
+
+func (cfg *ApiConfig) moderateReportedChirp(
+	w http.ResponseWriter,
+	req *http.Request,
+	resolution string,
+	apply func(queries *database.Queries, ctx context.Context, id uuid.UUID) (int64, error),
+) {
+	moderator := userFromContext(req.Context())
+
+	chirpID := req.PathValue("chirpID")
+	id, err := uuid.Parse(chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Error getting chirp id from request", err)
+		return
+	}
+
+	// the reports stay open if the chirp could not be moderated
+	tx, err := cfg.DB.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error moderating chirp in database", err)
+		return
+	}
+	defer tx.Rollback()
+	queries := cfg.Database.WithTx(tx)
+
+	// resolve first, deleting the chirp detaches its reports from it
+	_, err = queries.ResolveChirpReports(req.Context(), database.ResolveChirpReportsParams{
+		ChirpID:    id,
+		ResolvedBy: uuid.NullUUID{UUID: moderator.ID, Valid: true},
+		Resolution: sql.NullString{String: resolution, Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error resolving reports in database", err)
+		return
+	}
+
+	numChanged, err := apply(queries, req.Context(), id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error moderating chirp in database", err)
+		return
+	}
+
+	if numChanged == 0 {
+		respondWithError(w, http.StatusNotFound, "Chirp does not exist", errors.New("No chirp with id "+id.String()))
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error moderating chirp in database", err)
+		return
+	}
+
+	log.Printf("Chirp %v %v by %v %v", id, resolution, moderator.Role, moderator.ID)
+	respondWithJSON(w, http.StatusNoContent, struct{}{})
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/thewerther/webserver/internal/auth"
+	"github.com/thewerther/webserver/internal/database"
+	"github.com/thewerther/webserver/internal/oidc"
+	"github.com/thewerther/webserver/internal/oidc/oidctest"
+)
+
+const (
+	// how long the user has to log in at the provider
+	oidcLoginExpiry = 10 * time.Minute
+	oidcStateCookie = "oidc_state"
+	oidcCookiePath  = "/api/auth/oidc"
+	// path the fake provider is served at with OIDC_ISSUER=fake
+	fakeIdPPath = "/fake-idp"
+)
+
+// loadOIDCProvider sets up login with the provider at OIDC_ISSUER. It returns
+// nil if OIDC_ISSUER is not set. With OIDC_ISSUER=fake a fake provider that
+// logs everyone in is returned as well, it has to be served at fakeIdPPath and
+// is only allowed on the dev platform.
+func loadOIDCProvider(baseURL string, isDev bool) (*oidc.Provider, http.Handler, error) {
+	issuer := os.Getenv("OIDC_ISSUER")
+	if issuer == "" {
+		return nil, nil, nil
+	}
+
+	clientID := os.Getenv("OIDC_CLIENT_ID")
+	clientSecret := os.Getenv("OIDC_CLIENT_SECRET")
+	redirectURL := os.Getenv("OIDC_REDIRECT_URL")
+	if redirectURL == "" {
+		redirectURL = baseURL + oidcCookiePath + "/callback"
+	}
+
+	var fakeIdP http.Handler
+	if issuer == "fake" {
+		if !isDev {
+			return nil, nil, errors.New("OIDC_ISSUER=fake is only allowed with PLATFORM=dev")
+		}
+
+		if clientID == "" {
+			clientID = "chirpy"
+		}
+		if clientSecret == "" {
+			clientSecret = "fake-secret"
+		}
+
+		issuer = baseURL + fakeIdPPath
+		provider, err := oidctest.New(issuer, clientID, clientSecret)
+		if err != nil {
+			return nil, nil, err
+		}
+		fakeIdP = provider
+	}
+
+	if clientID == "" || clientSecret == "" {
+		return nil, nil, errors.New("OIDC_CLIENT_ID and OIDC_CLIENT_SECRET have to be set with OIDC_ISSUER")
+	}
+
+	return oidc.NewProvider(oidc.Config{
+		Issuer:       issuer,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       []string{"email", "profile"},
+	}), fakeIdP, nil
+}
+
+// startOIDCLogin redirects the user to the provider. The state is kept in a
+// cookie as well, so the callback can only be completed in the browser that
+// started the login.
+func (cfg *ApiConfig) startOIDCLogin(w http.ResponseWriter, req *http.Request) {
+	state, err := oidc.NewState()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating state", err)
+		return
+	}
+
+	nonce, err := oidc.NewState()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating nonce", err)
+		return
+	}
+
+	codeVerifier, codeChallenge, err := oidc.NewPKCE()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating code verifier", err)
+		return
+	}
+
+	authURL, err := cfg.OIDC.AuthCodeURL(req.Context(), state, nonce, codeChallenge)
+	if err != nil {
+		respondWithError(w, http.StatusBadGateway, "Identity provider is not available", err)
+		return
+	}
+
+	now := time.Now().UTC()
+	expiresAt := now.Add(oidcLoginExpiry)
+	_, err = cfg.Database.CreateOIDCLoginState(req.Context(), database.CreateOIDCLoginStateParams{
+		Now:          now,
+		StateHash:    auth.HashToken(state),
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+		ExpiresAt:    expiresAt,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error saving login state", err)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     oidcCookiePath,
+		Expires:  expiresAt,
+		MaxAge:   int(oidcLoginExpiry.Seconds()),
+		Secure:   strings.HasPrefix(cfg.OIDC.RedirectURL(), "https://"),
+		HttpOnly: true,
+		// the provider redirects back with a top level GET
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, req, authURL, http.StatusFound)
+}
+
+// oidcCallback finishes the login the provider redirected back from. The user
+// the identity is linked to is logged in like with a password.
+func (cfg *ApiConfig) oidcCallback(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+
+	// the login state can not be used again either way
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Path:     oidcCookiePath,
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+
+	if query.Get("error") != "" {
+		respondWithError(w, http.StatusUnauthorized, "Login at identity provider failed", errors.New(query.Get("error")+": "+query.Get("error_description")))
+		return
+	}
+
+	cookie, err := req.Cookie(oidcStateCookie)
+	if err != nil || cookie.Value == "" || cookie.Value != query.Get("state") {
+		respondWithError(w, http.StatusBadRequest, "State does not match", err)
+		return
+	}
+
+	loginState, err := cfg.Database.UseOIDCLoginState(req.Context(), database.UseOIDCLoginStateParams{
+		StateHash: auth.HashToken(cookie.Value),
+		Now:       time.Now().UTC(),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusBadRequest, "Login expired, please try again", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting login state", err)
+		return
+	}
+
+	idToken, err := cfg.OIDC.Exchange(req.Context(), query.Get("code"), loginState.CodeVerifier, loginState.Nonce)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error logging in with identity provider", err)
+		return
+	}
+
+	userExists, ok := cfg.userForIdentity(w, req, idToken)
+	if !ok {
+		return
+	}
+
+	if userExists.DeletedAt.Valid {
+		respondWithError(w, http.StatusForbidden, "User has been deleted", nil)
+		return
+	}
+
+	cfg.finishLogin(w, req, userExists)
+}
+
+// userForIdentity returns the user an identity is linked to. An unknown
+// identity is linked to the user with the same email address if both the
+// provider and Chirpy verified it, otherwise a new user is created.
+func (cfg *ApiConfig) userForIdentity(w http.ResponseWriter, req *http.Request, idToken *oidc.IDToken) (database.User, bool) {
+	issuer := cfg.OIDC.Issuer()
+
+	userExists, err := cfg.Database.GetUserByIdentity(req.Context(), database.GetUserByIdentityParams{
+		Issuer:  issuer,
+		Subject: idToken.Subject,
+	})
+	if err == nil {
+		err = cfg.Database.TouchUserIdentity(req.Context(), database.TouchUserIdentityParams{
+			Issuer:  issuer,
+			Subject: idToken.Subject,
+			Email:   idToken.Email,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error updating identity", err)
+			return database.User{}, false
+		}
+		return userExists, true
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusInternalServerError, "Error querying identity from database", err)
+		return database.User{}, false
+	}
+
+	err = validateEmail(idToken.Email)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Identity provider did not return a valid email", err)
+		return database.User{}, false
+	}
+
+	userExists, err = cfg.Database.GetUserByEmail(req.Context(), idToken.Email)
+	if err == nil {
+		// otherwise whoever controls one side could take over the other
+		if !idToken.EmailVerified || !userExists.EmailVerifiedAt.Valid {
+			respondWithError(w, http.StatusConflict, "Email is already registered, log in with your password", nil)
+			return database.User{}, false
+		}
+
+		_, err = cfg.Database.CreateUserIdentity(req.Context(), database.CreateUserIdentityParams{
+			UserID:  userExists.ID,
+			Issuer:  issuer,
+			Subject: idToken.Subject,
+			Email:   idToken.Email,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error linking identity", err)
+			return database.User{}, false
+		}
+		log.Printf("Linked identity %s of %s to user %v", idToken.Subject, issuer, userExists.ID)
+
+		return userExists, true
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusInternalServerError, "Error querying user from database", err)
+		return database.User{}, false
+	}
+
+	handle, err := cfg.handleForIdentity(req.Context(), idToken)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error picking a handle", err)
+		return database.User{}, false
+	}
+
+	newUser, err := cfg.Database.CreateOIDCUser(req.Context(), database.CreateOIDCUserParams{
+		Email:         idToken.Email,
+		Handle:        handle,
+		DisplayName:   truncateRunes(strings.TrimSpace(idToken.Name), maxDisplayNameLength),
+		EmailVerified: idToken.EmailVerified,
+		Issuer:        issuer,
+		Subject:       idToken.Subject,
+	})
+	if err != nil {
+		// the email may belong to a deleted user
+		respondWithError(w, http.StatusConflict, "Error creating user in database", err)
+		return database.User{}, false
+	}
+	log.Printf("Created user: %v\n", newUser)
+
+	return newUser, true
+}
+
+// handleForIdentity derives a free handle from the username or email of an
+// identity, a random suffix is added if it is taken.
+func (cfg *ApiConfig) handleForIdentity(ctx context.Context, idToken *oidc.IDToken) (string, error) {
+	name := idToken.PreferredUsername
+	if name == "" {
+		name, _, _ = strings.Cut(idToken.Email, "@")
+	}
+
+	base := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_':
+			return r
+		case r == '.' || r == '-':
+			return '_'
+		default:
+			return -1
+		}
+	}, strings.ToLower(name))
+	// leaves room for the suffix
+	base = truncateRunes(base, 24)
+	if _, reserved := reservedHandles[base]; reserved || len(base) < 3 {
+		base = "user"
+	}
+
+	handle := base
+	for range 5 {
+		if handlePattern.MatchString(handle) {
+			exists, err := cfg.Database.HandleExists(ctx, handle)
+			if err != nil {
+				return "", err
+			}
+			if !exists {
+				return handle, nil
+			}
+		}
+
+		suffix, err := auth.MakeRefreshToken()
+		if err != nil {
+			return "", err
+		}
+		handle = base + "_" + suffix[:5]
+	}
+
+	return "", fmt.Errorf("no free handle for %q", base)
+}
+
+func truncateRunes(s string, maxRunes int) string {
+	if utf8.RuneCountInString(s) <= maxRunes {
+		return s
+	}
+
+	return string([]rune(s)[:maxRunes])
+}
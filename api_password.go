@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/thewerther/webserver/internal/auth"
+	"github.com/thewerther/webserver/internal/database"
+)
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// forgotPassword emails a reset token if an account with the email exists.
+// It always responds with 202 and sends the email in the background, so
+// neither the response nor its timing reveal whether the account exists.
+func (cfg *ApiConfig) forgotPassword(w http.ResponseWriter, req *http.Request) {
+	forgotReq := ForgotPasswordRequest{}
+	err := decodeRequestBody(&forgotReq, req)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error decoding request", err)
+		return
+	}
+
+	userExists, err := cfg.Database.GetUserByEmail(req.Context(), forgotReq.Email)
+	if err == nil {
+		go func(user database.User) {
+			err := cfg.sendPasswordResetEmail(context.Background(), user)
+			if err != nil {
+				log.Printf("Error sending password reset email to user %v: %v", user.ID, err)
+			}
+		}(userExists)
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Error querying user for password reset: %v", err)
+	}
+
+	respondWithJSON(w, http.StatusAccepted, struct{}{})
+}
+
+// resetPassword sets a new password using an emailed token, logging the user
+// out everywhere by revoking all of their refresh tokens.
+func (cfg *ApiConfig) resetPassword(w http.ResponseWriter, req *http.Request) {
+	resetReq := ResetPasswordRequest{}
+	err := decodeRequestBody(&resetReq, req)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error decoding request", err)
+		return
+	}
+
+	if resetReq.Password == "" {
+		respondWithError(w, http.StatusBadRequest, "", errors.New("No password supplied!"))
+		return
+	}
+
+	// the email is unknown until the token is used, so only the other rules
+	// are checked
+	hashedPassword, ok := cfg.hashNewPassword(w, resetReq.Password, "")
+	if !ok {
+		return
+	}
+
+	resetUser, err := cfg.Database.ResetPassword(req.Context(), database.ResetPasswordParams{
+		Now:            time.Now().UTC(),
+		TokenHash:      auth.HashToken(resetReq.Token),
+		HashedPassword: hashedPassword,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusBadRequest, "Invalid or expired password reset token", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error resetting password in database", err)
+		return
+	}
+
+	// refresh tokens are revoked by ResetPassword already
+	err = cfg.revokeUserAccessTokens(req.Context(), resetUser.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error revoking access tokens", err)
+		return
+	}
+
+	// whoever reset the password may not be the one who created them
+	err = cfg.Database.DeleteUserPersonalAccessTokens(req.Context(), resetUser.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error revoking personal access tokens", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusNoContent, struct{}{})
+}
@@ -1,27 +1,275 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"errors"
 	"net/http"
-	"sort"
-	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/thewerther/webserver/internal/database"
 )
 
+const maxChirpLength = 140
+
 type ChirpRequest struct {
-	Body string `json:"body"`
+	Body      string     `json:"body"`
+	ReplyToID *uuid.UUID `json:"reply_to_id"`
+	// ids of images uploaded with POST /api/media
+	MediaIDs []uuid.UUID `json:"media_ids"`
+	// schedules the chirp instead of publishing it right away
+	PublishAt *time.Time `json:"publish_at"`
 }
 
 type ChirpResponse struct {
 	ID        uuid.UUID `json:"id"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
-	Email     string    `json:"email"`
+	Handle    string    `json:"handle"`
 	UserID    uuid.UUID `json:"user_id"`
 	Body      string    `json:"body"`
+	LikeCount int64     `json:"like_count"`
+	LikedByMe bool      `json:"liked_by_me"`
+	// null for top level chirps, still set if the parent has been deleted
+	ReplyToID  *uuid.UUID `json:"reply_to_id"`
+	ReplyCount int64      `json:"reply_count"`
+	// plain rechirps have an empty body and embed the original chirp, quotes
+	// embed the quoted chirp, both are omitted once the original is deleted
+	RechirpOfID *uuid.UUID     `json:"rechirp_of_id"`
+	RechirpOf   *ChirpResponse `json:"rechirp_of,omitempty"`
+	QuoteOfID   *uuid.UUID     `json:"quote_of_id"`
+	QuoteOf     *ChirpResponse `json:"quote_of,omitempty"`
+	// only users that exist are returned, other `@` tokens are plain text
+	Mentions []MentionEntity `json:"mentions"`
+	Media    []MediaResponse `json:"media"`
+	// only set while the chirp is scheduled and not published yet
+	PublishAt *time.Time `json:"publish_at,omitempty"`
+}
+
+type ChirpPageResponse struct {
+	Chirps     []ChirpResponse `json:"chirps"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+// newChirpPage trims a result fetched with limit+1 rows down to limit and
+// sets NextCursor if the extra row was present.
+func newChirpPage(dbChirps []database.Chirp, limit int32) ChirpPageResponse {
+	page := ChirpPageResponse{Chirps: []ChirpResponse{}}
+	if len(dbChirps) > int(limit) {
+		dbChirps = dbChirps[:limit]
+		last := dbChirps[len(dbChirps)-1]
+		page.NextCursor = encodeCursor(pageCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+
+	for _, chirp := range dbChirps {
+		page.Chirps = append(page.Chirps, chirpToResponse(chirp))
+	}
+
+	return page
+}
+
+func chirpToResponse(chirp database.Chirp) ChirpResponse {
+	response := ChirpResponse{
+		ID:        chirp.ID,
+		CreatedAt: chirp.CreatedAt,
+		UpdatedAt: chirp.UpdatedAt,
+		UserID:    chirp.UserID,
+		Body:      chirp.Body,
+	}
+	if chirp.ReplyToID.Valid {
+		response.ReplyToID = &chirp.ReplyToID.UUID
+	}
+	if chirp.RechirpOfID.Valid {
+		response.RechirpOfID = &chirp.RechirpOfID.UUID
+	}
+	if chirp.QuoteOfID.Valid {
+		response.QuoteOfID = &chirp.QuoteOfID.UUID
+	}
+	if chirp.PublishAt.Valid {
+		response.PublishAt = &chirp.PublishAt.Time
+	}
+
+	return response
+}
+
+// enrichChirpResponses fills in everything ChirpResponse has on top of the
+// chirps table. It runs a fixed number of queries per call no matter how many
+// chirps are passed in, viewerID may be null for anonymous requests.
+func (cfg *ApiConfig) enrichChirpResponses(ctx context.Context, chirps []ChirpResponse, viewerID uuid.NullUUID) error {
+	if len(chirps) == 0 {
+		return nil
+	}
+
+	originals, err := cfg.loadOriginalChirps(ctx, chirps)
+	if err != nil {
+		return err
+	}
+
+	return embedOriginals(chirps, originals, func(all []ChirpResponse) error {
+		err := cfg.addChirpStats(ctx, all, viewerID)
+		if err != nil {
+			return err
+		}
+
+		err = cfg.addChirpMentions(ctx, all)
+		if err != nil {
+			return err
+		}
+
+		return cfg.addChirpMedia(ctx, all)
+	})
+}
+
+// embedOriginals runs enrich over chirps and the originals they rechirp or
+// quote in one go, then embeds the enriched originals into chirps. Originals
+// are only embedded one level deep.
+func embedOriginals(chirps, originals []ChirpResponse, enrich func(all []ChirpResponse) error) error {
+	// a fresh slice, appending to chirps could write past its length into
+	// memory the caller still uses or into a copy the caller never sees
+	all := make([]ChirpResponse, 0, len(chirps)+len(originals))
+	all = append(all, chirps...)
+	all = append(all, originals...)
+
+	err := enrich(all)
+	if err != nil {
+		return err
+	}
+	copy(chirps, all[:len(chirps)])
+
+	originalsByID := map[uuid.UUID]ChirpResponse{}
+	for _, original := range all[len(chirps):] {
+		originalsByID[original.ID] = original
+	}
+
+	for idx := range chirps {
+		if chirps[idx].RechirpOfID != nil {
+			if original, exists := originalsByID[*chirps[idx].RechirpOfID]; exists {
+				chirps[idx].RechirpOf = &original
+			}
+		}
+		if chirps[idx].QuoteOfID != nil {
+			if original, exists := originalsByID[*chirps[idx].QuoteOfID]; exists {
+				chirps[idx].QuoteOf = &original
+			}
+		}
+	}
+
+	return nil
+}
+
+// loadOriginalChirps fetches all chirps rechirped or quoted by chirps that
+// have not been deleted or hidden.
+func (cfg *ApiConfig) loadOriginalChirps(ctx context.Context, chirps []ChirpResponse) ([]ChirpResponse, error) {
+	originalIDs := []uuid.UUID{}
+	for _, chirp := range chirps {
+		if chirp.RechirpOfID != nil {
+			originalIDs = append(originalIDs, *chirp.RechirpOfID)
+		}
+		if chirp.QuoteOfID != nil {
+			originalIDs = append(originalIDs, *chirp.QuoteOfID)
+		}
+	}
+
+	if len(originalIDs) == 0 {
+		return nil, nil
+	}
+
+	dbOriginals, err := cfg.Database.GetChirpsByIDs(ctx, originalIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	originals := []ChirpResponse{}
+	for _, original := range dbOriginals {
+		originals = append(originals, chirpToResponse(original))
+	}
+
+	return originals, nil
+}
+
+func (cfg *ApiConfig) addChirpStats(ctx context.Context, chirps []ChirpResponse, viewerID uuid.NullUUID) error {
+	chirpIDs := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		chirpIDs = append(chirpIDs, chirp.ID)
+	}
+
+	chirpStats, err := cfg.Database.GetChirpStats(ctx, database.GetChirpStatsParams{
+		ViewerID: viewerID,
+		ChirpIds: chirpIDs,
+	})
+	if err != nil {
+		return err
+	}
+
+	statsByChirp := map[uuid.UUID]database.GetChirpStatsRow{}
+	for _, stats := range chirpStats {
+		statsByChirp[stats.ChirpID] = stats
+	}
+
+	for idx := range chirps {
+		stats := statsByChirp[chirps[idx].ID]
+		chirps[idx].LikeCount = stats.LikeCount
+		chirps[idx].LikedByMe = stats.LikedByViewer
+		chirps[idx].ReplyCount = stats.ReplyCount
+		chirps[idx].Handle = stats.AuthorHandle
+	}
+
+	return nil
+}
+
+// dedupeChirps drops plain rechirps of chirps that are already on the page,
+// keeping whichever comes first. Pages can end up shorter than the limit
+// because of this, NextCursor still points past all of them.
+func dedupeChirps(chirps []ChirpResponse) []ChirpResponse {
+	seen := map[uuid.UUID]struct{}{}
+	deduped := []ChirpResponse{}
+
+	for _, chirp := range chirps {
+		contentID := chirp.ID
+		if chirp.RechirpOfID != nil {
+			contentID = *chirp.RechirpOfID
+		}
+
+		if _, exists := seen[contentID]; exists {
+			continue
+		}
+		seen[contentID] = struct{}{}
+		deduped = append(deduped, chirp)
+	}
+
+	return deduped
+}
+
+// respondWithChirpPage responds with a page of chirps fetched with limit+1 rows.
+func (cfg *ApiConfig) respondWithChirpPage(w http.ResponseWriter, req *http.Request, dbChirps []database.Chirp, limit int32) {
+	cfg.respondWithPage(w, req, newChirpPage(dbChirps, limit))
+}
+
+// respondWithPage responds with a page that has already been trimmed to its
+// limit.
+func (cfg *ApiConfig) respondWithPage(w http.ResponseWriter, req *http.Request, page ChirpPageResponse) {
+	page.Chirps = dedupeChirps(page.Chirps)
+
+	err := cfg.enrichChirpResponses(req.Context(), page.Chirps, optionalViewerID(req, cfg))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error querying chirp details from database", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, page)
+}
+
+func (cfg *ApiConfig) respondWithChirp(w http.ResponseWriter, req *http.Request, code int, chirp database.Chirp) {
+	response := []ChirpResponse{chirpToResponse(chirp)}
+
+	err := cfg.enrichChirpResponses(req.Context(), response, optionalViewerID(req, cfg))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error querying chirp details from database", err)
+		return
+	}
+
+	respondWithJSON(w, code, response[0])
 }
 
 func (cfg *ApiConfig) createChirp(w http.ResponseWriter, req *http.Request) {
@@ -32,107 +280,156 @@ func (cfg *ApiConfig) createChirp(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	userExists, err := authenticate(req, cfg)
+	userExists, err := authenticate(req, cfg, scopeChirpsWrite)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		respondWithAuthError(w, err)
+		return
+	}
+
+	cfg.saveNewChirp(w, req, userExists, chirpReq, uuid.NullUUID{})
+}
+
+// saveNewChirp validates, moderates and stores a chirp written by userExists,
+// quoteOfID is set for quote chirps.
+func (cfg *ApiConfig) saveNewChirp(
+	w http.ResponseWriter,
+	req *http.Request,
+	userExists database.User,
+	chirpReq ChirpRequest,
+	quoteOfID uuid.NullUUID,
+) {
+	if cfg.RequireVerifiedEmail && !userExists.EmailVerifiedAt.Valid {
+		respondWithError(w, http.StatusForbidden, "Verify your email address before chirping", nil)
 		return
 	}
 
-	if len(chirpReq.Body) > 140 {
+	if len(chirpReq.Body) > maxChirpLength {
 		respondWithError(w, http.StatusBadRequest, "", errors.New("Chirp is too long!"))
 		return
 	}
 
-	newChirp, err := cfg.Database.CreateChirp(
+	replyToID := uuid.NullUUID{}
+	if chirpReq.ReplyToID != nil {
+		// replying to deleted or hidden chirps is not possible
+		parent, err := cfg.Database.GetChirpByID(req.Context(), *chirpReq.ReplyToID)
+		if err != nil {
+			respondWithError(w, http.StatusNotFound, "Chirp to reply to does not exist", err)
+			return
+		}
+		replyToID = uuid.NullUUID{UUID: parent.ID, Valid: true}
+	}
+
+	now := time.Now().UTC()
+	publishAt, err := parsePublishAt(chirpReq.PublishAt, now)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid publish_at", err)
+		return
+	}
+
+	err = cfg.validateMediaIDs(req.Context(), userExists.ID, chirpReq.MediaIDs)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid media_ids", err)
+		return
+	}
+
+	moderated := cfg.Moderation.Moderate(chirpReq.Body)
+	if moderated.Rejected {
+		respondWithError(w, http.StatusUnprocessableEntity, moderated.RejectionError().Error(), nil)
+		return
+	}
+	// masks can be longer than the text they replace
+	if len(moderated.Body) > maxChirpLength {
+		respondWithError(w, http.StatusBadRequest, "", errors.New("Chirp is too long once filtered words are masked!"))
+		return
+	}
+
+	// the chirp is only stored if all of its media could be attached
+	tx, err := cfg.DB.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating Chirp in database", err)
+		return
+	}
+	defer tx.Rollback()
+	queries := cfg.Database.WithTx(tx)
+
+	newChirp, err := queries.CreateChirp(
 		req.Context(),
 		database.CreateChirpParams{
-			Body:   cleanBody(chirpReq.Body),
-			UserID: userExists.ID,
+			Body:      moderated.Body,
+			UserID:    userExists.ID,
+			ReplyToID: replyToID,
+			QuoteOfID: quoteOfID,
+			PublishAt: publishAt,
+			CreatedAt: now,
 		})
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error creating Chirp in database", err)
 		return
 	}
 
-	response := ChirpResponse{
-		ID:        newChirp.ID,
-		CreatedAt: newChirp.CreatedAt,
-		UpdatedAt: newChirp.UpdatedAt,
-		Email:     userExists.Email,
-		UserID:    userExists.ID,
-		Body:      newChirp.Body,
+	err = attachMedia(req.Context(), queries, newChirp, chirpReq.MediaIDs)
+	if errors.Is(err, errMediaNotAttachable) {
+		respondWithError(w, http.StatusBadRequest, "Invalid media_ids", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error attaching media to chirp", err)
+		return
 	}
 
-	respondWithJSON(w, http.StatusCreated, response)
-}
-
-func cleanBody(body string) string {
-	profaneWords := map[string]struct{}{
-		"kerfuffle": {},
-		"sharbert":  {},
-		"fornax":    {},
-		"Kerfuffle": {},
-		"Sharbert":  {},
-		"Fornax":    {},
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating Chirp in database", err)
+		return
 	}
 
-	msgWords := strings.Split(body, " ")
-	for idx, word := range msgWords {
-		if _, exists := profaneWords[word]; exists {
-			msgWords[idx] = strings.ReplaceAll(msgWords[idx], word, strings.Repeat("*", 4))
-		}
+	cfg.flagChirpForReview(req.Context(), newChirp.ID, moderated)
+	cfg.storeChirpEntities(req.Context(), newChirp.ID, newChirp.Body)
+	if newChirp.PublishAt.Valid {
+		cfg.wakeScheduler()
 	}
 
-	return strings.Join(msgWords, " ")
-}
+	response := []ChirpResponse{chirpToResponse(newChirp)}
 
-func (cfg *ApiConfig) getChirps(w http.ResponseWriter, req *http.Request) {
-	dbChirps, err := cfg.Database.GetChirps(req.Context())
+	err = cfg.enrichChirpResponses(req.Context(), response, uuid.NullUUID{UUID: userExists.ID, Valid: true})
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Error querying chirps by user id from database", err)
+		respondWithError(w, http.StatusInternalServerError, "Error querying chirp details from database", err)
 		return
 	}
 
-  authorID := uuid.Nil
-	authorIDParam := req.URL.Query().Get("author_id")
-	if authorIDParam != "" {
-		authorID, err = uuid.Parse(authorIDParam)
-		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, "Error parsing user id fro mrequest", err)
-			return
-		}
-	}
+	respondWithJSON(w, http.StatusCreated, response[0])
+}
 
-	// default is "asc"
-	sortDir := "asc"
-	sortParam := req.URL.Query().Get("sort")
-	if sortParam == "desc" {
-		sortDir = "desc"
+func (cfg *ApiConfig) getChirps(w http.ResponseWriter, req *http.Request) {
+	params, err := parseChirpListParams(req.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid query parameters", err)
+		return
 	}
 
-	sortedChirps := []ChirpResponse{}
-	for _, chirp := range dbChirps {
-		if authorID != uuid.Nil && chirp.UserID != authorID {
-			continue
-		}
-
-		sortedChirps = append(sortedChirps, ChirpResponse{
-			ID:        chirp.ID,
-			CreatedAt: chirp.CreatedAt,
-			UpdatedAt: chirp.UpdatedAt,
-			UserID:    chirp.UserID,
-			Body:      chirp.Body,
+	// fetch one extra row to find out whether there is a next page
+	var dbChirps []database.Chirp
+	if params.Descending {
+		dbChirps, err = cfg.Database.GetChirpsPageDesc(req.Context(), database.GetChirpsPageDescParams{
+			AuthorID:        params.AuthorID,
+			CursorCreatedAt: params.CursorCreatedAt,
+			CursorID:        params.CursorID,
+			PageLimit:       params.Limit + 1,
+		})
+	} else {
+		dbChirps, err = cfg.Database.GetChirpsPageAsc(req.Context(), database.GetChirpsPageAscParams{
+			AuthorID:        params.AuthorID,
+			CursorCreatedAt: params.CursorCreatedAt,
+			CursorID:        params.CursorID,
+			PageLimit:       params.Limit + 1,
 		})
 	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error querying chirps from database", err)
+		return
+	}
 
-	sort.Slice(sortedChirps, func(i, j int) bool {
-		if sortDir == "desc" {
-			return sortedChirps[i].CreatedAt.After(sortedChirps[j].CreatedAt)
-		}
-		return sortedChirps[i].CreatedAt.Before(sortedChirps[j].CreatedAt)
-	})
-
-	respondWithJSON(w, http.StatusOK, sortedChirps)
+	cfg.respondWithChirpPage(w, req, dbChirps, params.Limit)
 }
 
 func (cfg *ApiConfig) getChirpByID(w http.ResponseWriter, req *http.Request) {
@@ -149,13 +446,13 @@ func (cfg *ApiConfig) getChirpByID(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, chirp)
+	cfg.respondWithChirp(w, req, http.StatusOK, chirp)
 }
 
 func (cfg *ApiConfig) deleteChirpByID(w http.ResponseWriter, req *http.Request) {
-	userExists, err := authenticate(req, cfg)
+	userExists, err := authenticate(req, cfg, scopeChirpsWrite)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		respondWithAuthError(w, err)
 		return
 	}
 
@@ -177,7 +474,10 @@ func (cfg *ApiConfig) deleteChirpByID(w http.ResponseWriter, req *http.Request)
 		return
 	}
 
-	err = cfg.Database.DeleteChirpByID(req.Context(), id)
+	err = cfg.Database.SoftDeleteChirpByID(req.Context(), database.SoftDeleteChirpByIDParams{
+		DeletedAt: time.Now().UTC(),
+		ID:        id,
+	})
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error deleting chirp from database", err)
 		return
@@ -185,3 +485,339 @@ func (cfg *ApiConfig) deleteChirpByID(w http.ResponseWriter, req *http.Request)
 
 	respondWithJSON(w, http.StatusNoContent, struct{}{})
 }
+
+type ChirpRevisionResponse struct {
+	ID         uuid.UUID `json:"id"`
+	ChirpID    uuid.UUID `json:"chirp_id"`
+	Body       string    `json:"body"`
+	CreatedAt  time.Time `json:"created_at"`
+	ReplacedAt time.Time `json:"replaced_at"`
+}
+
+func (cfg *ApiConfig) updateChirp(w http.ResponseWriter, req *http.Request) {
+	userExists, err := authenticate(req, cfg, scopeChirpsWrite)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	chirpID := req.PathValue("chirpID")
+	id, err := uuid.Parse(chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Error getting chirp id from request", err)
+		return
+	}
+
+	chirpReq := ChirpRequest{}
+	err = decodeRequestBody(&chirpReq, req)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error Decoding request", err)
+		return
+	}
+
+	chirpExists, err := cfg.Database.GetChirpByID(req.Context(), id)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Error querying chirp by id", err)
+		return
+	}
+
+	if chirpExists.UserID != userExists.ID {
+		respondWithError(w, http.StatusForbidden, "Cannot edit another users chirp", errors.New("User is not the author of the chirp"))
+		return
+	}
+
+	if chirpExists.RechirpOfID.Valid {
+		respondWithError(w, http.StatusBadRequest, "Cannot edit a rechirp", nil)
+		return
+	}
+
+	if len(chirpReq.Body) > maxChirpLength {
+		respondWithError(w, http.StatusBadRequest, "", errors.New("Chirp is too long!"))
+		return
+	}
+
+	moderated := cfg.Moderation.Moderate(chirpReq.Body)
+	if moderated.Rejected {
+		respondWithError(w, http.StatusUnprocessableEntity, moderated.RejectionError().Error(), nil)
+		return
+	}
+	// masks can be longer than the text they replace
+	if len(moderated.Body) > maxChirpLength {
+		respondWithError(w, http.StatusBadRequest, "", errors.New("Chirp is too long once filtered words are masked!"))
+		return
+	}
+
+	tx, err := cfg.DB.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating chirp in database", err)
+		return
+	}
+	defer tx.Rollback()
+	queries := cfg.Database.WithTx(tx)
+
+	// the revision has to snapshot the body this edit replaces, not the one
+	// read before another edit went through
+	_, err = queries.LockChirpForEdit(req.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Chirp does not exist", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating chirp in database", err)
+		return
+	}
+
+	updatedChirp, err := queries.UpdateChirpBody(
+		req.Context(),
+		database.UpdateChirpBodyParams{
+			ID:   id,
+			Body: moderated.Body,
+		})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating chirp in database", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating chirp in database", err)
+		return
+	}
+	cfg.flagChirpForReview(req.Context(), updatedChirp.ID, moderated)
+	cfg.storeChirpEntities(req.Context(), updatedChirp.ID, updatedChirp.Body)
+
+	cfg.respondWithChirp(w, req, http.StatusOK, updatedChirp)
+}
+
+func (cfg *ApiConfig) getChirpRevisions(w http.ResponseWriter, req *http.Request) {
+	chirpID := req.PathValue("chirpID")
+	id, err := uuid.Parse(chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Error getting chirp id from request", err)
+		return
+	}
+
+	_, err = cfg.Database.GetChirpByID(req.Context(), id)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Error querying chirp by id", err)
+		return
+	}
+
+	dbRevisions, err := cfg.Database.GetChirpRevisions(req.Context(), id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error querying chirp revisions from database", err)
+		return
+	}
+
+	revisions := []ChirpRevisionResponse{}
+	for _, revision := range dbRevisions {
+		revisions = append(revisions, ChirpRevisionResponse{
+			ID:         revision.ID,
+			ChirpID:    revision.ChirpID,
+			Body:       revision.Body,
+			CreatedAt:  revision.CreatedAt,
+			ReplacedAt: revision.ReplacedAt,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, revisions)
+}
+
+func (cfg *ApiConfig) restoreChirp(w http.ResponseWriter, req *http.Request) {
+	userExists, err := authenticate(req, cfg, scopeChirpsWrite)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	chirpID := req.PathValue("chirpID")
+	id, err := uuid.Parse(chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Error getting chirp id from request", err)
+		return
+	}
+
+	deletedChirp, err := cfg.Database.GetDeletedChirpByID(req.Context(), id)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Error querying deleted chirp by id", err)
+		return
+	}
+
+	if deletedChirp.UserID != userExists.ID && userExists.Role != roleAdmin {
+		respondWithError(w, http.StatusForbidden, "Cannot restore another users chirp", errors.New("User is not the author of the chirp"))
+		return
+	}
+
+	restoredChirp, err := cfg.Database.RestoreChirpByID(
+		req.Context(),
+		database.RestoreChirpByIDParams{
+			ID:           id,
+			DeletedAfter: time.Now().UTC().Add(-cfg.RestoreWindow),
+		})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusGone, "Chirp can no longer be restored", err)
+		return
+	}
+	// a deleted rechirp can not come back once the chirp was rechirped again
+	if isUniqueViolation(err, "chirps_user_id_rechirp_of_id_idx") {
+		respondWithError(w, http.StatusConflict, "Chirp has been rechirped again since", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error restoring chirp in database", err)
+		return
+	}
+
+	cfg.respondWithChirp(w, req, http.StatusOK, restoredChirp)
+}
+
+// getReplies returns the direct replies to a chirp, oldest first.
+func (cfg *ApiConfig) getReplies(w http.ResponseWriter, req *http.Request) {
+	chirpID := req.PathValue("chirpID")
+	id, err := uuid.Parse(chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Error getting chirp id from request", err)
+		return
+	}
+
+	// the replies of a deleted chirp stay visible until it is purged
+	published, err := cfg.Database.ChirpWasPublished(req.Context(), id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error querying chirp by id", err)
+		return
+	}
+	if !published {
+		respondWithError(w, http.StatusNotFound, "Chirp not found", nil)
+		return
+	}
+
+	params, err := parsePageParams(req.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid query parameters", err)
+		return
+	}
+
+	dbChirps, err := cfg.Database.GetRepliesPage(req.Context(), database.GetRepliesPageParams{
+		ReplyToID:       uuid.NullUUID{UUID: id, Valid: true},
+		CursorCreatedAt: params.CursorCreatedAt,
+		CursorID:        params.CursorID,
+		PageLimit:       params.Limit + 1,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error querying replies from database", err)
+		return
+	}
+
+	cfg.respondWithChirpPage(w, req, dbChirps, params.Limit)
+}
+
+// rechirpChirp shares a chirp as it is, rechirping a rechirp shares its original.
+func (cfg *ApiConfig) rechirpChirp(w http.ResponseWriter, req *http.Request) {
+	userExists, err := authenticate(req, cfg, scopeChirpsWrite)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	if cfg.RequireVerifiedEmail && !userExists.EmailVerifiedAt.Valid {
+		respondWithError(w, http.StatusForbidden, "Verify your email address before chirping", nil)
+		return
+	}
+
+	original, err := cfg.getOriginalChirpFromPath(req)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Error querying chirp by id", err)
+		return
+	}
+
+	rechirp, err := cfg.Database.CreateRechirp(req.Context(), database.CreateRechirpParams{
+		UserID:      userExists.ID,
+		RechirpOfID: uuid.NullUUID{UUID: original.ID, Valid: true},
+		CreatedAt:   time.Now().UTC(),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusConflict, "Chirp has already been rechirped", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating rechirp in database", err)
+		return
+	}
+
+	cfg.respondWithChirp(w, req, http.StatusCreated, rechirp)
+}
+
+func (cfg *ApiConfig) undoRechirp(w http.ResponseWriter, req *http.Request) {
+	userExists, err := authenticate(req, cfg, scopeChirpsWrite)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	chirpID := req.PathValue("chirpID")
+	id, err := uuid.Parse(chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Error getting chirp id from request", err)
+		return
+	}
+
+	numDeleted, err := cfg.Database.DeleteRechirp(req.Context(), database.DeleteRechirpParams{
+		UserID:      userExists.ID,
+		RechirpOfID: uuid.NullUUID{UUID: id, Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error deleting rechirp from database", err)
+		return
+	}
+
+	if numDeleted == 0 {
+		respondWithError(w, http.StatusNotFound, "Chirp has not been rechirped", nil)
+		return
+	}
+
+	respondWithJSON(w, http.StatusNoContent, struct{}{})
+}
+
+// quoteChirp creates a chirp with its own text that embeds another chirp.
+func (cfg *ApiConfig) quoteChirp(w http.ResponseWriter, req *http.Request) {
+	chirpReq := ChirpRequest{}
+	err := decodeRequestBody(&chirpReq, req)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error Decoding request", err)
+		return
+	}
+
+	userExists, err := authenticate(req, cfg, scopeChirpsWrite)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	original, err := cfg.getOriginalChirpFromPath(req)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Error querying chirp by id", err)
+		return
+	}
+
+	cfg.saveNewChirp(w, req, userExists, chirpReq, uuid.NullUUID{UUID: original.ID, Valid: true})
+}
+
+// getOriginalChirpFromPath looks up the chirp in the path, following plain
+// rechirps to the chirp they share.
+func (cfg *ApiConfig) getOriginalChirpFromPath(req *http.Request) (database.Chirp, error) {
+	id, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	chirp, err := cfg.Database.GetChirpByID(req.Context(), id)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	if chirp.RechirpOfID.Valid {
+		return cfg.Database.GetChirpByID(req.Context(), chirp.RechirpOfID.UUID)
+	}
+
+	return chirp, nil
+}
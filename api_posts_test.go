@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestEmbedOriginalsSingleQuote(t *testing.T) {
+	original := ChirpResponse{ID: uuid.New(), Body: "original"}
+	quote := ChirpResponse{ID: uuid.New(), Body: "quote", QuoteOfID: &original.ID}
+
+	// a single chirp without spare capacity, like respondWithChirp passes in
+	chirps := []ChirpResponse{quote}
+
+	err := embedOriginals(chirps, []ChirpResponse{original}, func(all []ChirpResponse) error {
+		for idx := range all {
+			all[idx].LikeCount = 3
+			all[idx].Handle = "handle_" + all[idx].Body
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Test EmbedOriginalsSingleQuote failed with err: %v", err)
+	}
+
+	if chirps[0].LikeCount != 3 || chirps[0].Handle != "handle_quote" {
+		t.Errorf("Test EmbedOriginalsSingleQuote failed: quote was not enriched: %+v", chirps[0])
+	}
+
+	if chirps[0].QuoteOf == nil {
+		t.Fatalf("Test EmbedOriginalsSingleQuote failed: quoted chirp was not embedded")
+	}
+	if chirps[0].QuoteOf.LikeCount != 3 || chirps[0].QuoteOf.Handle != "handle_original" {
+		t.Errorf("Test EmbedOriginalsSingleQuote failed: quoted chirp was not enriched: %+v", chirps[0].QuoteOf)
+	}
+}
+
+func TestEmbedOriginalsKeepsCallerSlice(t *testing.T) {
+	original := ChirpResponse{ID: uuid.New(), Body: "original"}
+	rechirp := ChirpResponse{ID: uuid.New(), RechirpOfID: &original.ID}
+	other := ChirpResponse{ID: uuid.New(), Body: "other"}
+
+	// spare capacity must not be written to
+	backing := make([]ChirpResponse, 2, 3)
+	backing[0], backing[1] = rechirp, other
+	backing = append(backing[:2], ChirpResponse{Body: "untouched"})[:2]
+
+	err := embedOriginals(backing, []ChirpResponse{original}, func(all []ChirpResponse) error {
+		for idx := range all {
+			all[idx].ReplyCount = 1
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if backing[:3][2].Body != "untouched" {
+		t.Errorf("Test EmbedOriginalsKeepsCallerSlice failed: spare capacity was overwritten")
+	}
+	if backing[0].RechirpOf == nil || backing[0].RechirpOf.ReplyCount != 1 || backing[1].ReplyCount != 1 {
+		t.Errorf("Test EmbedOriginalsKeepsCallerSlice failed: chirps were not enriched: %+v", backing)
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/thewerther/webserver/internal/database"
+)
+
+const (
+	maxDisplayNameLength = 50
+	maxBioLength         = 160
+)
+
+var handlePattern = regexp.MustCompile(`^[a-z0-9_]{3,30}$`)
+
+// fixedUserRoutes are the routes under /api/users next to the profiles at
+// GET /api/users/{handle}.
+func (cfg *ApiConfig) fixedUserRoutes() map[string]http.HandlerFunc {
+	return map[string]http.HandlerFunc{
+		"POST /api/users/restore":       cfg.restoreUser,
+		"POST /api/users/verify":        cfg.verifyEmail,
+		"POST /api/users/verify/resend": cfg.resendVerificationEmail,
+		"POST /api/users/2fa/setup":     cfg.setupTwoFactor,
+		"POST /api/users/2fa/confirm":   cfg.confirmTwoFactor,
+		"DELETE /api/users/2fa":         cfg.disableTwoFactor,
+	}
+}
+
+// reservedHandles would be ambiguous next to the fixed routes under /api/users.
+var reservedHandles = reservedRouteSegments(new(ApiConfig).fixedUserRoutes(), "admin")
+
+// reservedRouteSegments returns the first path segment after /api/users/ of
+// every route along with extra.
+func reservedRouteSegments(routes map[string]http.HandlerFunc, extra ...string) map[string]struct{} {
+	reserved := map[string]struct{}{}
+	for pattern := range routes {
+		_, path, _ := strings.Cut(pattern, " ")
+		segment, _, _ := strings.Cut(strings.TrimPrefix(path, "/api/users/"), "/")
+		reserved[segment] = struct{}{}
+	}
+	for _, name := range extra {
+		reserved[name] = struct{}{}
+	}
+
+	return reserved
+}
+
+// ProfileRequest updates the public profile of a user, fields that are left
+// out keep their current value.
+type ProfileRequest struct {
+	Handle      *string `json:"handle"`
+	DisplayName *string `json:"display_name"`
+	Bio         *string `json:"bio"`
+}
+
+type ProfileResponse struct {
+	ID          uuid.UUID `json:"id"`
+	Handle      string    `json:"handle"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	JoinedAt    time.Time `json:"joined_at"`
+	ChirpCount  int64     `json:"chirp_count"`
+}
+
+type profile struct {
+	Handle      string
+	DisplayName string
+	Bio         string
+}
+
+// validateProfile normalizes a handle to lower case and checks the length of
+// all profile fields.
+func validateProfile(handle, displayName, bio string) (profile, error) {
+	handle = strings.ToLower(strings.TrimPrefix(handle, "@"))
+	if !handlePattern.MatchString(handle) {
+		return profile{}, errors.New("handle has to be 3 to 30 letters, digits or underscores")
+	}
+	if _, reserved := reservedHandles[handle]; reserved {
+		return profile{}, fmt.Errorf("handle %q is reserved", handle)
+	}
+
+	displayName = strings.TrimSpace(displayName)
+	if utf8.RuneCountInString(displayName) > maxDisplayNameLength {
+		return profile{}, fmt.Errorf("display_name can be at most %d characters", maxDisplayNameLength)
+	}
+
+	bio = strings.TrimSpace(bio)
+	if utf8.RuneCountInString(bio) > maxBioLength {
+		return profile{}, fmt.Errorf("bio can be at most %d characters", maxBioLength)
+	}
+
+	return profile{Handle: handle, DisplayName: displayName, Bio: bio}, nil
+}
+
+// isHandleTaken responds with an error if handle can not be used for a new
+// user or profile.
+func (cfg *ApiConfig) isHandleTaken(w http.ResponseWriter, req *http.Request, handle string) bool {
+	exists, err := cfg.Database.HandleExists(req.Context(), handle)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error querying handle from database", err)
+		return true
+	}
+
+	if exists {
+		respondWithError(w, http.StatusConflict, "Handle is already taken", nil)
+		return true
+	}
+
+	return false
+}
+
+func (cfg *ApiConfig) updateProfile(w http.ResponseWriter, req *http.Request) {
+	profileReq := ProfileRequest{}
+	err := decodeRequestBody(&profileReq, req)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error decoding request", err)
+		return
+	}
+
+	userExists, err := authenticate(req, cfg, scopeProfileWrite)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	handle, displayName, bio := userExists.Handle, userExists.DisplayName, userExists.Bio
+	if profileReq.Handle != nil {
+		handle = *profileReq.Handle
+	}
+	if profileReq.DisplayName != nil {
+		displayName = *profileReq.DisplayName
+	}
+	if profileReq.Bio != nil {
+		bio = *profileReq.Bio
+	}
+
+	updated, err := validateProfile(handle, displayName, bio)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid profile", err)
+		return
+	}
+
+	if updated.Handle != userExists.Handle && cfg.isHandleTaken(w, req, updated.Handle) {
+		return
+	}
+
+	updatedUser, err := cfg.Database.UpdateUserProfile(req.Context(), database.UpdateUserProfileParams{
+		ID:          userExists.ID,
+		Handle:      updated.Handle,
+		DisplayName: updated.DisplayName,
+		Bio:         updated.Bio,
+	})
+	// the handle may have been taken since it was checked
+	if isUniqueViolation(err, "users_handle_key") {
+		respondWithError(w, http.StatusConflict, "Handle is already taken", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating profile in database", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, userToResponse(updatedUser))
+}
+
+func (cfg *ApiConfig) getProfile(w http.ResponseWriter, req *http.Request) {
+	handle := strings.ToLower(strings.TrimPrefix(req.PathValue("handle"), "@"))
+
+	dbProfile, err := cfg.Database.GetUserProfileByHandle(req.Context(), handle)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "User does not exist", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error querying profile from database", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, ProfileResponse{
+		ID:          dbProfile.ID,
+		Handle:      dbProfile.Handle,
+		DisplayName: dbProfile.DisplayName,
+		Bio:         dbProfile.Bio,
+		JoinedAt:    dbProfile.CreatedAt,
+		ChirpCount:  dbProfile.ChirpCount,
+	})
+}
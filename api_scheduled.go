@@ -0,0 +1,92 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/thewerther/webserver/internal/database"
+)
+
+// chirps can be scheduled at most this far into the future
+const maxScheduleAhead = 365 * 24 * time.Hour
+
+// parsePublishAt validates the `publish_at` of a ChirpRequest against the
+// current time now, chirps without one are published right away.
+func parsePublishAt(publishAt *time.Time, now time.Time) (sql.NullTime, error) {
+	if publishAt == nil {
+		return sql.NullTime{}, nil
+	}
+
+	if !publishAt.After(now) {
+		return sql.NullTime{}, errors.New("publish_at has to be in the future")
+	}
+	if publishAt.After(now.Add(maxScheduleAhead)) {
+		return sql.NullTime{}, errors.New("publish_at can be at most a year in the future")
+	}
+
+	return sql.NullTime{Time: publishAt.UTC(), Valid: true}, nil
+}
+
+// getScheduledChirps lists the pending chirps of the user, the ones due first
+// come first.
+func (cfg *ApiConfig) getScheduledChirps(w http.ResponseWriter, req *http.Request) {
+	userExists, err := authenticate(req, cfg, scopeChirpsRead)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	dbChirps, err := cfg.Database.GetScheduledChirps(req.Context(), userExists.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error querying scheduled chirps from database", err)
+		return
+	}
+
+	response := ChirpPageResponse{Chirps: []ChirpResponse{}}
+	for _, chirp := range dbChirps {
+		response.Chirps = append(response.Chirps, chirpToResponse(chirp))
+	}
+
+	err = cfg.enrichChirpResponses(req.Context(), response.Chirps, uuid.NullUUID{UUID: userExists.ID, Valid: true})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error querying chirp details from database", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// cancelScheduledChirp deletes a pending chirp for good, published chirps
+// have to be deleted with DELETE /api/chirps/{chirpID}.
+func (cfg *ApiConfig) cancelScheduledChirp(w http.ResponseWriter, req *http.Request) {
+	userExists, err := authenticate(req, cfg, scopeChirpsWrite)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	id, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Error getting chirp id from request", err)
+		return
+	}
+
+	numDeleted, err := cfg.Database.DeleteScheduledChirp(req.Context(), database.DeleteScheduledChirpParams{
+		ID:     id,
+		UserID: userExists.ID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error deleting scheduled chirp from database", err)
+		return
+	}
+
+	if numDeleted == 0 {
+		respondWithError(w, http.StatusNotFound, "No scheduled chirp with this id", nil)
+		return
+	}
+
+	respondWithJSON(w, http.StatusNoContent, struct{}{})
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestParsePublishAt(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		publishAt := now.Add(d)
+		return &publishAt
+	}
+
+	tests := map[string]struct {
+		publishAt *time.Time
+		valid     bool
+		wantErr   bool
+	}{
+		"not scheduled":      {publishAt: nil},
+		"in the past":        {publishAt: at(-time.Minute), wantErr: true},
+		"right now":          {publishAt: at(0), wantErr: true},
+		"in a minute":        {publishAt: at(time.Minute), valid: true},
+		"in exactly a year":  {publishAt: at(maxScheduleAhead), valid: true},
+		"later than a year":  {publishAt: at(maxScheduleAhead + time.Second), wantErr: true},
+		"ten years from now": {publishAt: at(10 * maxScheduleAhead), wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			publishAt, err := parsePublishAt(tc.publishAt, now)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("Test ParsePublishAt %s: expected an error, got %v", name, publishAt)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Test ParsePublishAt %s failed with err: %v", name, err)
+			}
+
+			if publishAt.Valid != tc.valid || (tc.valid && !publishAt.Time.Equal(*tc.publishAt)) {
+				t.Errorf("Test ParsePublishAt %s: unexpected result %v", name, publishAt)
+			}
+		})
+	}
+}
+
+func TestParsePublishAtConvertsToUTC(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	publishAt := now.Add(time.Hour).In(time.FixedZone("UTC+2", 2*60*60))
+
+	parsed, err := parsePublishAt(&publishAt, now)
+	if err != nil {
+		t.Fatalf("Test ParsePublishAtConvertsToUTC failed with err: %v", err)
+	}
+
+	if parsed.Time.Location() != time.UTC || !parsed.Time.Equal(publishAt) {
+		t.Errorf("Test ParsePublishAtConvertsToUTC: expected %v in UTC, got %v", publishAt, parsed.Time)
+	}
+}
+
+func TestNextPublishIn(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	due := func(d time.Duration) sql.NullTime {
+		return sql.NullTime{Time: now.Add(d), Valid: true}
+	}
+
+	tests := map[string]struct {
+		publishAt sql.NullTime
+		expected  time.Duration
+	}{
+		"nothing scheduled":  {publishAt: sql.NullTime{}, expected: maxSchedulerSleep},
+		"overdue":            {publishAt: due(-time.Hour), expected: 0},
+		"due now":            {publishAt: due(0), expected: 0},
+		"due in 10 seconds":  {publishAt: due(10 * time.Second), expected: 10 * time.Second},
+		"due in a minute":    {publishAt: due(maxSchedulerSleep), expected: maxSchedulerSleep},
+		"due in a month":     {publishAt: due(30 * 24 * time.Hour), expected: maxSchedulerSleep},
+		"due in a long time": {publishAt: due(maxScheduleAhead), expected: maxSchedulerSleep},
+	}
+
+	for name, tc := range tests {
+		actual := nextPublishIn(tc.publishAt, now)
+		if actual != tc.expected {
+			t.Errorf("Test NextPublishIn %s: expected %v, got %v", name, tc.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"net/url"
+
+	"github.com/google/uuid"
+	"github.com/thewerther/webserver/internal/database"
+	"github.com/thewerther/webserver/internal/search"
+)
+
+// searchChirps ranks chirps by relevance to `q` unless `sort` is given, in
+// which case results are ordered by creation date. Both are paginated with
+// `limit` and `cursor` and respond with the same page as getChirps.
+func (cfg *ApiConfig) searchChirps(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+
+	tsQuery, err := search.BuildTSQuery(query.Get("q"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid search query", err)
+		return
+	}
+
+	sortParam := query.Get("sort")
+	byRank := sortParam == "" || sortParam == "rank"
+
+	listQuery := query
+	if byRank {
+		// the rank cursor is decoded by searchChirpsByRank
+		listQuery = url.Values{}
+		for key, values := range query {
+			if key != "cursor" {
+				listQuery[key] = values
+			}
+		}
+	}
+
+	params, err := parseChirpListParams(listQuery)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid query parameters", err)
+		return
+	}
+
+	if byRank {
+		cfg.searchChirpsByRank(w, req, tsQuery, params, query.Get("cursor"))
+		return
+	}
+
+	var dbChirps []database.Chirp
+	if params.Descending {
+		dbChirps, err = cfg.Database.SearchChirpsDesc(req.Context(), database.SearchChirpsDescParams{
+			Query:           tsQuery,
+			AuthorID:        params.AuthorID,
+			CursorCreatedAt: params.CursorCreatedAt,
+			CursorID:        params.CursorID,
+			PageLimit:       params.Limit + 1,
+		})
+	} else {
+		dbChirps, err = cfg.Database.SearchChirpsAsc(req.Context(), database.SearchChirpsAscParams{
+			Query:           tsQuery,
+			AuthorID:        params.AuthorID,
+			CursorCreatedAt: params.CursorCreatedAt,
+			CursorID:        params.CursorID,
+			PageLimit:       params.Limit + 1,
+		})
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error searching chirps in database", err)
+		return
+	}
+
+	cfg.respondWithChirpPage(w, req, dbChirps, params.Limit)
+}
+
+func (cfg *ApiConfig) searchChirpsByRank(w http.ResponseWriter, req *http.Request, tsQuery string, params chirpListParams, cursorParam string) {
+	cursorRank := sql.NullFloat64{}
+	cursorID := uuid.NullUUID{}
+	if cursorParam != "" {
+		cursor, err := decodeRankCursor(cursorParam)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid query parameters", err)
+			return
+		}
+		cursorRank = sql.NullFloat64{Float64: float64(cursor.Rank), Valid: true}
+		cursorID = uuid.NullUUID{UUID: cursor.ID, Valid: true}
+	}
+
+	rows, err := cfg.Database.SearchChirpsByRank(req.Context(), database.SearchChirpsByRankParams{
+		Query:      tsQuery,
+		AuthorID:   params.AuthorID,
+		CursorRank: cursorRank,
+		CursorID:   cursorID,
+		PageLimit:  params.Limit + 1,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error searching chirps in database", err)
+		return
+	}
+
+	page := ChirpPageResponse{Chirps: []ChirpResponse{}}
+	if len(rows) > int(params.Limit) {
+		rows = rows[:params.Limit]
+		last := rows[len(rows)-1]
+		page.NextCursor = encodeRankCursor(rankCursor{Rank: last.Rank, ID: last.ID})
+	}
+
+	for _, row := range rows {
+		page.Chirps = append(page.Chirps, chirpToResponse(database.Chirp{
+			ID:          row.ID,
+			Body:        row.Body,
+			CreatedAt:   row.CreatedAt,
+			UpdatedAt:   row.UpdatedAt,
+			UserID:      row.UserID,
+			DeletedAt:   row.DeletedAt,
+			HiddenAt:    row.HiddenAt,
+			ReplyToID:   row.ReplyToID,
+			RechirpOfID: row.RechirpOfID,
+			QuoteOfID:   row.QuoteOfID,
+			PublishAt:   row.PublishAt,
+		}))
+	}
+
+	cfg.respondWithPage(w, req, page)
+}
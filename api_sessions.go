@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/thewerther/webserver/internal/auth"
+	"github.com/thewerther/webserver/internal/database"
+)
+
+// longer user agents are cut off before they are stored
+const maxUserAgentLength = 512
+
+// SessionResponse is a login on one device. Its ID stays the same while the
+// refresh token is rotated.
+type SessionResponse struct {
+	ID         uuid.UUID `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	StartedAt  time.Time `json:"started_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+type SessionsResponse struct {
+	Sessions []SessionResponse `json:"sessions"`
+}
+
+// LogoutRequest can name the refresh token of the device to end its session
+// along with the access token.
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// clientIP is the address the request came from. Headers like
+// X-Forwarded-For are ignored since any client can set them.
+func clientIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+
+	return host
+}
+
+func clientUserAgent(req *http.Request) string {
+	userAgent := req.UserAgent()
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+
+	return userAgent
+}
+
+// getSessions lists the devices the user is logged in on, the most recently
+// used first.
+func (cfg *ApiConfig) getSessions(w http.ResponseWriter, req *http.Request) {
+	user, err := authenticateSession(req, cfg)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return
+	}
+
+	sessions, err := cfg.Database.GetUserSessions(req.Context(), database.GetUserSessionsParams{
+		UserID: user.ID,
+		Now:    time.Now().UTC(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error querying sessions", err)
+		return
+	}
+
+	resp := SessionsResponse{Sessions: []SessionResponse{}}
+	for _, session := range sessions {
+		resp.Sessions = append(resp.Sessions, sessionToResponse(session))
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+func sessionToResponse(session database.GetUserSessionsRow) SessionResponse {
+	return SessionResponse{
+		ID:         session.FamilyID,
+		UserAgent:  session.UserAgent,
+		IPAddress:  session.IpAddress,
+		StartedAt:  session.StartedAt,
+		LastUsedAt: session.LastUsedAt,
+		ExpiresAt:  session.ExpiresAt,
+	}
+}
+
+// revokeSession logs the user out on one device. Access tokens already handed
+// out stay valid until they expire.
+func (cfg *ApiConfig) revokeSession(w http.ResponseWriter, req *http.Request) {
+	user, err := authenticateSession(req, cfg)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return
+	}
+
+	sessionID, err := uuid.Parse(req.PathValue("sessionID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Error getting session id from request", err)
+		return
+	}
+
+	numRevoked, err := cfg.Database.RevokeUserSession(req.Context(), database.RevokeUserSessionParams{
+		FamilyID: sessionID,
+		UserID:   user.ID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error revoking session", err)
+		return
+	}
+	if numRevoked == 0 {
+		respondWithError(w, http.StatusNotFound, "Session does not exist", errors.New("no active session with this id"))
+		return
+	}
+
+	respondWithJSON(w, http.StatusNoContent, struct{}{})
+}
+
+// revokeAllSessions logs the user out on all devices, the one sending the
+// request included. Access tokens stop working right away as well.
+func (cfg *ApiConfig) revokeAllSessions(w http.ResponseWriter, req *http.Request) {
+	user, err := authenticateSession(req, cfg)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return
+	}
+
+	err = cfg.logOutEverywhere(req.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error revoking sessions", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusNoContent, struct{}{})
+}
+
+// logout revokes the access token of the request right away instead of
+// letting it expire, and the session of the refresh token if one is given.
+func (cfg *ApiConfig) logout(w http.ResponseWriter, req *http.Request) {
+	claims, err := parseAccessToken(req, cfg)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return
+	}
+
+	logoutReq := LogoutRequest{}
+	err = decodeRequestBody(&logoutReq, req)
+	// the body is optional
+	if err != nil && !errors.Is(err, io.EOF) {
+		respondWithError(w, http.StatusInternalServerError, "Error decoding request", err)
+		return
+	}
+
+	// tokens issued before they had an ID expire within the hour
+	if claims.ID != "" {
+		err = cfg.revokeAccessToken(req.Context(), claims)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error revoking access token", err)
+			return
+		}
+	}
+
+	if logoutReq.RefreshToken != "" {
+		err = cfg.Database.SetRefreshTokenRevokedAt(req.Context(), auth.HashToken(logoutReq.RefreshToken))
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error revoking refresh token", err)
+			return
+		}
+	}
+
+	respondWithJSON(w, http.StatusNoContent, struct{}{})
+}
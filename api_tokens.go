@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/thewerther/webserver/internal/auth"
+	"github.com/thewerther/webserver/internal/database"
+)
+
+const (
+	scopeChirpsRead   = "chirps:read"
+	scopeChirpsWrite  = "chirps:write"
+	scopeFollowsWrite = "follows:write"
+	scopeProfileWrite = "profile:write"
+
+	maxTokenNameLength = 100
+)
+
+var validScopes = []string{scopeChirpsRead, scopeChirpsWrite, scopeFollowsWrite, scopeProfileWrite}
+
+type PersonalAccessTokenRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// optional, tokens without expiry stay valid until they are deleted
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type PersonalAccessTokenResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	// only returned when the token is created
+	Token string `json:"token,omitempty"`
+}
+
+func personalAccessTokenToResponse(token database.PersonalAccessToken) PersonalAccessTokenResponse {
+	response := PersonalAccessTokenResponse{
+		ID:        token.ID,
+		Name:      token.Name,
+		Scopes:    token.Scopes,
+		CreatedAt: token.CreatedAt,
+	}
+	if token.ExpiresAt.Valid {
+		response.ExpiresAt = &token.ExpiresAt.Time
+	}
+	if token.LastUsedAt.Valid {
+		response.LastUsedAt = &token.LastUsedAt.Time
+	}
+
+	return response
+}
+
+// usePersonalAccessToken looks up a personal access token and checks that it
+// carries scope.
+func (cfg *ApiConfig) usePersonalAccessToken(ctx context.Context, token, scope string) (database.PersonalAccessToken, error) {
+	personalToken, err := cfg.Database.UsePersonalAccessToken(ctx, database.UsePersonalAccessTokenParams{
+		Now:       time.Now().UTC(),
+		TokenHash: auth.HashToken(token),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.PersonalAccessToken{}, errors.New("Personal access token does not exist or has expired")
+	}
+	if err != nil {
+		return database.PersonalAccessToken{}, err
+	}
+
+	if !slices.Contains(personalToken.Scopes, scope) {
+		return database.PersonalAccessToken{}, fmt.Errorf("%w: %s", errInsufficientScope, scope)
+	}
+
+	return personalToken, nil
+}
+
+// validateScopes removes duplicates and rejects unknown scopes.
+func validateScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, errors.New("at least one scope is required")
+	}
+
+	valid := []string{}
+	for _, scope := range scopes {
+		if !slices.Contains(validScopes, scope) {
+			return nil, fmt.Errorf("unknown scope %q, has to be one of %s", scope, strings.Join(validScopes, ", "))
+		}
+		if !slices.Contains(valid, scope) {
+			valid = append(valid, scope)
+		}
+	}
+
+	return valid, nil
+}
+
+func (cfg *ApiConfig) createPersonalAccessToken(w http.ResponseWriter, req *http.Request) {
+	user, err := authenticateSession(req, cfg)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return
+	}
+
+	tokenReq := PersonalAccessTokenRequest{}
+	err = decodeRequestBody(&tokenReq, req)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error decoding request", err)
+		return
+	}
+
+	name := strings.TrimSpace(tokenReq.Name)
+	if name == "" || utf8.RuneCountInString(name) > maxTokenNameLength {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("name has to be 1 to %d characters", maxTokenNameLength), nil)
+		return
+	}
+
+	scopes, err := validateScopes(tokenReq.Scopes)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid scopes", err)
+		return
+	}
+
+	now := time.Now().UTC()
+	expiresAt := sql.NullTime{}
+	if tokenReq.ExpiresAt != nil {
+		if !tokenReq.ExpiresAt.After(now) {
+			respondWithError(w, http.StatusBadRequest, "expires_at has to be in the future", nil)
+			return
+		}
+		expiresAt = sql.NullTime{Time: tokenReq.ExpiresAt.UTC(), Valid: true}
+	}
+
+	token, err := auth.MakePersonalAccessToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating personal access token", err)
+		return
+	}
+
+	personalToken, err := cfg.Database.CreatePersonalAccessToken(req.Context(), database.CreatePersonalAccessTokenParams{
+		UserID:    user.ID,
+		Name:      name,
+		TokenHash: auth.HashToken(token),
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+		CreatedAt: now,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error saving personal access token", err)
+		return
+	}
+
+	response := personalAccessTokenToResponse(personalToken)
+	response.Token = token
+
+	respondWithJSON(w, http.StatusCreated, response)
+}
+
+func (cfg *ApiConfig) getPersonalAccessTokens(w http.ResponseWriter, req *http.Request) {
+	user, err := authenticateSession(req, cfg)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return
+	}
+
+	tokens, err := cfg.Database.GetPersonalAccessTokens(req.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting personal access tokens", err)
+		return
+	}
+
+	response := []PersonalAccessTokenResponse{}
+	for _, token := range tokens {
+		response = append(response, personalAccessTokenToResponse(token))
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func (cfg *ApiConfig) deletePersonalAccessToken(w http.ResponseWriter, req *http.Request) {
+	user, err := authenticateSession(req, cfg)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return
+	}
+
+	tokenID, err := uuid.Parse(req.PathValue("tokenID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Personal access token not found", err)
+		return
+	}
+
+	deleted, err := cfg.Database.DeletePersonalAccessToken(req.Context(), database.DeletePersonalAccessTokenParams{
+		ID:     tokenID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error deleting personal access token", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "Personal access token not found", nil)
+		return
+	}
+
+	respondWithJSON(w, http.StatusNoContent, struct{}{})
+}
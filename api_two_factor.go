@@ -0,0 +1,373 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/thewerther/webserver/internal/auth"
+	"github.com/thewerther/webserver/internal/database"
+)
+
+const (
+	totpIssuer               = "Chirpy"
+	recoveryCodeCount        = 10
+	twoFactorChallengeExpiry = 5 * time.Minute
+	// wrong codes allowed per challenge before the user has to log in again
+	maxTwoFactorAttempts = 5
+	// wrong codes allowed per user across all challenges before the second
+	// step is locked for secondFactorLockout
+	maxSecondFactorFailures = 10
+	secondFactorLockout     = 15 * time.Minute
+)
+
+type TOTPSetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type TOTPConfirmRequest struct {
+	Code string `json:"code"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// TwoFactorChallengeResponse is returned by /api/login instead of the tokens
+// if the user has 2FA enabled.
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool      `json:"two_factor_required"`
+	ChallengeToken    string    `json:"challenge_token"`
+	ExpiresAt         time.Time `json:"expires_at"`
+}
+
+// TwoFactorLoginRequest needs either a code of the authenticator app or one
+// of the recovery codes.
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code"`
+	RecoveryCode   string `json:"recovery_code"`
+}
+
+type DisableTwoFactorRequest struct {
+	Password     string `json:"password"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// setupTwoFactor creates a new TOTP secret for the user. 2FA is only enabled
+// once a code of it has been sent to confirmTwoFactor.
+func (cfg *ApiConfig) setupTwoFactor(w http.ResponseWriter, req *http.Request) {
+	user, err := authenticateSession(req, cfg)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating TOTP secret", err)
+		return
+	}
+
+	totp, err := cfg.Database.StartTOTPSetup(req.Context(), database.StartTOTPSetupParams{
+		UserID: user.ID,
+		Secret: secret,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error storing TOTP secret", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, TOTPSetupResponse{
+		Secret:     totp.Secret,
+		OTPAuthURI: auth.TOTPURI(totpIssuer, user.Email, totp.Secret),
+	})
+}
+
+// confirmTwoFactor enables 2FA after the user proved their authenticator app
+// works and returns the recovery codes. They are only shown this once.
+func (cfg *ApiConfig) confirmTwoFactor(w http.ResponseWriter, req *http.Request) {
+	user, err := authenticateSession(req, cfg)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return
+	}
+
+	confirmReq := TOTPConfirmRequest{}
+	err = decodeRequestBody(&confirmReq, req)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error decoding request", err)
+		return
+	}
+
+	totp, err := cfg.Database.GetUserTOTP(req.Context(), user.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusBadRequest, "Two-factor authentication has not been set up", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error querying TOTP secret", err)
+		return
+	}
+
+	if totp.EnabledAt.Valid {
+		respondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled", nil)
+		return
+	}
+
+	ok, err := cfg.useTOTPCode(req.Context(), totp, confirmReq.Code)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error checking code", err)
+		return
+	}
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Invalid code", nil)
+		return
+	}
+
+	codes, err := auth.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating recovery codes", err)
+		return
+	}
+
+	codeHashes := []string{}
+	for _, code := range codes {
+		codeHashes = append(codeHashes, auth.HashToken(auth.NormalizeRecoveryCode(code)))
+	}
+
+	err = cfg.Database.ReplaceRecoveryCodes(req.Context(), database.ReplaceRecoveryCodesParams{
+		UserID:     user.ID,
+		CodeHashes: codeHashes,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error storing recovery codes", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// disableTwoFactor needs the password and a second factor on top of the access
+// token, so a stolen access token is not enough to turn 2FA off.
+func (cfg *ApiConfig) disableTwoFactor(w http.ResponseWriter, req *http.Request) {
+	user, err := authenticateSession(req, cfg)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return
+	}
+
+	disableReq := DisableTwoFactorRequest{}
+	err = decodeRequestBody(&disableReq, req)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error decoding request", err)
+		return
+	}
+
+	match, _, err := cfg.PasswordHasher.Verify(disableReq.Password, user.HashedPassword)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error verifying password", err)
+		return
+	}
+	if !match {
+		respondWithError(w, http.StatusUnauthorized, "Incorrect password", nil)
+		return
+	}
+
+	ok, err := cfg.verifySecondFactor(req.Context(), user.ID, disableReq.Code, disableReq.RecoveryCode)
+	if errors.Is(err, errSecondFactorLocked) {
+		respondWithError(w, http.StatusTooManyRequests, err.Error(), nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error checking code", err)
+		return
+	}
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Invalid code", nil)
+		return
+	}
+
+	err = cfg.Database.DisableTwoFactor(req.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error disabling two-factor authentication", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusNoContent, struct{}{})
+}
+
+// respondWithTwoFactorChallenge is the first step of logging in with 2FA. The
+// challenge token proves the password was correct for a few minutes.
+func (cfg *ApiConfig) respondWithTwoFactorChallenge(w http.ResponseWriter, req *http.Request, user database.User) {
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating challenge token", err)
+		return
+	}
+
+	expiresAt := time.Now().UTC().Add(twoFactorChallengeExpiry)
+	err = cfg.Database.CreateTwoFactorChallenge(req.Context(), database.CreateTwoFactorChallengeParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    user.ID,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error storing challenge token", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, TwoFactorChallengeResponse{
+		TwoFactorRequired: true,
+		ChallengeToken:    token,
+		ExpiresAt:         expiresAt,
+	})
+}
+
+// loginTwoFactor is the second step of logging in with 2FA.
+func (cfg *ApiConfig) loginTwoFactor(w http.ResponseWriter, req *http.Request) {
+	loginReq := TwoFactorLoginRequest{}
+	err := decodeRequestBody(&loginReq, req)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error decoding request", err)
+		return
+	}
+
+	tokenHash := auth.HashToken(loginReq.ChallengeToken)
+	userID, err := cfg.Database.AttemptTwoFactorChallenge(req.Context(), database.AttemptTwoFactorChallengeParams{
+		TokenHash:   tokenHash,
+		Now:         time.Now().UTC(),
+		MaxAttempts: maxTwoFactorAttempts,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusUnauthorized, "Invalid or expired challenge token", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error querying challenge token", err)
+		return
+	}
+
+	user, err := cfg.Database.GetUserById(req.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusUnauthorized, "Invalid or expired challenge token", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error querying user", err)
+		return
+	}
+
+	ok, err := cfg.verifySecondFactor(req.Context(), user.ID, loginReq.Code, loginReq.RecoveryCode)
+	if errors.Is(err, errSecondFactorLocked) {
+		respondWithError(w, http.StatusTooManyRequests, err.Error(), nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error checking code", err)
+		return
+	}
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Invalid code", nil)
+		return
+	}
+
+	err = cfg.Database.DeleteTwoFactorChallenge(req.Context(), tokenHash)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error removing challenge token", err)
+		return
+	}
+
+	cfg.respondWithLogin(w, req, user)
+}
+
+var errSecondFactorLocked = errors.New("Too many wrong codes, try again later")
+
+// verifySecondFactor checks a code of the authenticator app or, if given, uses
+// up a recovery code. Wrong codes are counted per user, no matter which
+// challenge they were sent with, and lock the user out for a while once there
+// are too many of them.
+func (cfg *ApiConfig) verifySecondFactor(ctx context.Context, userID uuid.UUID, code, recoveryCode string) (bool, error) {
+	now := time.Now().UTC()
+	totp, err := cfg.Database.AttemptSecondFactor(ctx, database.AttemptSecondFactorParams{
+		MaxAttempts: maxSecondFactorFailures,
+		LockUntil:   now.Add(secondFactorLockout),
+		UserID:      userID,
+		Now:         now,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		// either 2FA is not enabled or the user is locked out
+		totp, err = cfg.Database.GetUserTOTP(ctx, userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if totp.EnabledAt.Valid {
+			return false, errSecondFactorLocked
+		}
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	ok := false
+	if recoveryCode != "" {
+		numUsed, err := cfg.Database.UseRecoveryCode(ctx, database.UseRecoveryCodeParams{
+			UserID:   userID,
+			CodeHash: auth.HashToken(auth.NormalizeRecoveryCode(recoveryCode)),
+		})
+		if err != nil {
+			return false, err
+		}
+		ok = numUsed == 1
+	} else {
+		ok, err = cfg.useTOTPCode(ctx, totp, code)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if !ok {
+		return false, nil
+	}
+
+	err = cfg.Database.ResetSecondFactorAttempts(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// useTOTPCode accepts every code only once, even within the time it is valid.
+func (cfg *ApiConfig) useTOTPCode(ctx context.Context, totp database.UserTotp, code string) (bool, error) {
+	step, ok, err := auth.ValidateTOTP(totp.Secret, code, time.Now())
+	if err != nil || !ok {
+		return false, err
+	}
+
+	_, err = cfg.Database.UseTOTPStep(ctx, database.UseTOTPStepParams{
+		Step:   step,
+		UserID: totp.UserID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
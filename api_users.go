@@ -1,27 +1,37 @@
 package main
 
 import (
+	"database/sql"
 	"errors"
 	"log"
 	"net/http"
-	"reflect"
+	"net/mail"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/thewerther/webserver/internal/auth"
 	"github.com/thewerther/webserver/internal/database"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type UserCreateRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email       string `json:"email"`
+	Password    string `json:"password"`
+	Handle      string `json:"handle"`
+	DisplayName string `json:"display_name"`
+	Bio         string `json:"bio"`
 }
 
 type UserCreateResponse struct {
-	Id        uuid.UUID `json:"id"`
-	Email     string    `json:"email"`
-	IsPremium bool      `json:"is_chirpy_red"`
+	Id          uuid.UUID `json:"id"`
+	Email       string    `json:"email"`
+	IsPremium   bool      `json:"is_chirpy_red"`
+	Role        string    `json:"role"`
+	Handle      string    `json:"handle"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	// false until the token emailed to the user is sent to /api/users/verify
+	EmailVerified bool `json:"email_verified"`
 }
 
 type LoginRequest struct {
@@ -34,9 +44,11 @@ type LoginResponse struct {
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 	Email        string    `json:"email"`
+	Handle       string    `json:"handle"`
 	Token        string    `json:"token"`
 	RefreshToken string    `json:"refresh_token"`
 	IsPremium    bool      `json:"is_chirpy_red"`
+	Role         string    `json:"role"`
 }
 
 type AuthRequest struct {
@@ -44,7 +56,42 @@ type AuthRequest struct {
 }
 
 type RefreshResponse struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// refresh tokens are replaced on every use, so this is how long a session can
+// stay unused
+const refreshTokenExpiry = 60 * 24 * time.Hour
+
+// validateEmail only accepts a bare address like "user@example.com".
+func validateEmail(email string) error {
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		return errors.New("email has to be a valid email address")
+	}
+
+	return nil
+}
+
+// isUniqueViolation reports whether err was caused by the unique constraint
+// named constraint.
+func isUniqueViolation(err error, constraint string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == constraint
+}
+
+func userToResponse(user database.User) UserCreateResponse {
+	return UserCreateResponse{
+		Id:            user.ID,
+		Email:         user.Email,
+		IsPremium:     user.IsPremium,
+		Role:          user.Role,
+		EmailVerified: user.EmailVerifiedAt.Valid,
+		Handle:        user.Handle,
+		DisplayName:   user.DisplayName,
+		Bio:           user.Bio,
+	}
 }
 
 func (cfg *ApiConfig) createUser(w http.ResponseWriter, req *http.Request) {
@@ -60,29 +107,57 @@ func (cfg *ApiConfig) createUser(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	hashedPswd, err := bcrypt.GenerateFromPassword([]byte(userReq.Password), 4)
+	err = validateEmail(userReq.Email)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Error hashing password", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid email", err)
+		return
+	}
+
+	profile, err := validateProfile(userReq.Handle, userReq.DisplayName, userReq.Bio)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid profile", err)
+		return
+	}
+
+	if cfg.isHandleTaken(w, req, profile.Handle) {
+		return
+	}
+
+	hashedPswd, ok := cfg.hashNewPassword(w, userReq.Password, userReq.Email)
+	if !ok {
 		return
 	}
 
 	newUser, err := cfg.Database.CreateUser(req.Context(), database.CreateUserParams{
 		Email:          userReq.Email,
-		HashedPassword: string(hashedPswd),
+		HashedPassword: hashedPswd,
+		Handle:         profile.Handle,
+		DisplayName:    profile.DisplayName,
+		Bio:            profile.Bio,
 	})
+	// deleted users keep their email until they are purged
+	if isUniqueViolation(err, "users_email_key") {
+		respondWithError(w, http.StatusConflict, "Email is already registered, a deleted user can be restored with POST /api/users/restore", err)
+		return
+	}
+	// the handle may have been taken since it was checked
+	if isUniqueViolation(err, "users_handle_key") {
+		respondWithError(w, http.StatusConflict, "Handle is already taken", err)
+		return
+	}
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error creating user in database", err)
 		return
 	}
 	log.Printf("Created user: %v\n", newUser)
 
-	newUserResp := UserCreateResponse{
-		Id:        newUser.ID,
-		Email:     newUser.Email,
-		IsPremium: newUser.IsPremium,
+	// the user can ask for a new email if sending fails
+	err = cfg.sendVerificationEmail(req.Context(), newUser)
+	if err != nil {
+		log.Printf("Error sending verification email to user %v: %v", newUser.ID, err)
 	}
 
-	respondWithJSON(w, http.StatusCreated, newUserResp)
+	respondWithJSON(w, http.StatusCreated, userToResponse(newUser))
 }
 
 func (cfg *ApiConfig) loginUser(w http.ResponseWriter, req *http.Request) {
@@ -92,9 +167,32 @@ func (cfg *ApiConfig) loginUser(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	cfg.finishLogin(w, req, userExists)
+}
+
+// finishLogin asks for the second factor if the user has 2FA enabled and
+// issues the tokens otherwise.
+func (cfg *ApiConfig) finishLogin(w http.ResponseWriter, req *http.Request, userExists database.User) {
+	twoFactorEnabled, err := cfg.Database.IsTwoFactorEnabled(req.Context(), userExists.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error checking two-factor authentication", err)
+		return
+	}
+	if twoFactorEnabled {
+		cfg.respondWithTwoFactorChallenge(w, req, userExists)
+		return
+	}
+
+	cfg.respondWithLogin(w, req, userExists)
+}
+
+// respondWithLogin issues an access and a refresh token for a user whose
+// credentials have been checked.
+func (cfg *ApiConfig) respondWithLogin(w http.ResponseWriter, req *http.Request, userExists database.User) {
 	signedToken, err := auth.MakeJWT(
 		userExists.ID,
-		cfg.JWT_Secret,
+		userExists.Role,
+		cfg.JWTKeys,
 		time.Duration(60*time.Second),
 	)
 	if err != nil {
@@ -108,14 +206,16 @@ func (cfg *ApiConfig) loginUser(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	const expirationTimeRefreshTokenInSeconds = time.Hour * 24 * 60
-	expiresAt := time.Now().UTC().Add(expirationTimeRefreshTokenInSeconds)
+	// every login starts a new token family
 	_, err = cfg.Database.CreateRefreshToken(
 		req.Context(),
 		database.CreateRefreshTokenParams{
-			Token:     refreshToken,
+			TokenHash: auth.HashToken(refreshToken),
 			UserID:    userExists.ID,
-			ExpiresAt: expiresAt,
+			ExpiresAt: time.Now().UTC().Add(refreshTokenExpiry),
+			FamilyID:  uuid.New(),
+			UserAgent: clientUserAgent(req),
+			IpAddress: clientIP(req),
 		})
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Refresh token creation failed", err)
@@ -125,11 +225,13 @@ func (cfg *ApiConfig) loginUser(w http.ResponseWriter, req *http.Request) {
 	loginResp := LoginResponse{
 		ID:           userExists.ID,
 		Email:        userExists.Email,
+		Handle:       userExists.Handle,
 		CreatedAt:    userExists.CreatedAt,
 		UpdatedAt:    userExists.UpdatedAt,
 		Token:        signedToken,
 		RefreshToken: refreshToken,
 		IsPremium:    userExists.IsPremium,
+		Role:         userExists.Role,
 	}
 
 	log.Println(loginResp)
@@ -137,6 +239,10 @@ func (cfg *ApiConfig) loginUser(w http.ResponseWriter, req *http.Request) {
 	respondWithJSON(w, http.StatusOK, loginResp)
 }
 
+// refreshToken rotates the refresh token: the one in the request is revoked
+// and a new one of the same family is returned with the access token. A token
+// that was already rotated being presented again means it has leaked, so the
+// whole family is revoked and the user has to log in again.
 func (cfg *ApiConfig) refreshToken(w http.ResponseWriter, req *http.Request) {
 	refreshToken, err := auth.GetBearerToken(req.Header)
 	if err != nil {
@@ -144,40 +250,82 @@ func (cfg *ApiConfig) refreshToken(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	dbRefreshToken, err := cfg.Database.GetRefreshToken(req.Context(), refreshToken)
+	newRefreshToken, err := auth.MakeRefreshToken()
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Refresh token does not exist", err)
+		respondWithError(w, http.StatusInternalServerError, "Error creating refresh token", err)
 		return
 	}
 
-	if dbRefreshToken.ExpiresAt.Before(time.Now().UTC()) {
-		respondWithError(w, http.StatusUnauthorized, "Refresh token expired", err)
+	now := time.Now().UTC()
+	rotatedToken, err := cfg.Database.RotateRefreshToken(req.Context(), database.RotateRefreshTokenParams{
+		OldTokenHash: auth.HashToken(refreshToken),
+		Now:          now,
+		NewTokenHash: auth.HashToken(newRefreshToken),
+		ExpiresAt:    now.Add(refreshTokenExpiry),
+		UserAgent:    clientUserAgent(req),
+		IpAddress:    clientIP(req),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		cfg.rejectRefreshToken(w, req, refreshToken)
 		return
 	}
-
-	if dbRefreshToken.RevokedAt.Valid {
-		respondWithError(w, http.StatusUnauthorized, "Refresh token has been revoked", err)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error rotating refresh token", err)
 		return
 	}
 
-	user, err := cfg.Database.GetUserFromRefreshToken(req.Context(), refreshToken)
+	user, err := cfg.Database.GetUserById(req.Context(), rotatedToken.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusUnauthorized, "Invalid refresh token", err)
+		return
+	}
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error querying user by refresh token", err)
 		return
 	}
 
-	if reflect.DeepEqual(database.User{}, user) {
-		respondWithError(w, http.StatusUnauthorized, "Invalid refresh token", err)
+	newAccessToken, err := auth.MakeJWT(user.ID, user.Role, cfg.JWTKeys, time.Hour)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating new acces token", err)
 		return
 	}
 
-	newAccessToken, err := auth.MakeJWT(user.ID, cfg.JWT_Secret, time.Hour)
+	respondWithJSON(w, http.StatusOK, RefreshResponse{
+		Token:        newAccessToken,
+		RefreshToken: newRefreshToken,
+	})
+}
+
+// rejectRefreshToken explains why a refresh token could not be rotated and
+// revokes its family if it had been rotated before.
+func (cfg *ApiConfig) rejectRefreshToken(w http.ResponseWriter, req *http.Request, refreshToken string) {
+	dbRefreshToken, err := cfg.Database.GetRefreshToken(req.Context(), auth.HashToken(refreshToken))
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusUnauthorized, "Refresh token does not exist", err)
+		return
+	}
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Error creating new acces token", err)
+		respondWithError(w, http.StatusInternalServerError, "Error querying refresh token", err)
+		return
+	}
+
+	if dbRefreshToken.ReplacedAt.Valid {
+		err = cfg.Database.RevokeRefreshTokenFamily(req.Context(), dbRefreshToken.FamilyID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error revoking refresh token family", err)
+			return
+		}
+		log.Printf("Refresh token reuse detected for user %v, revoked token family %v", dbRefreshToken.UserID, dbRefreshToken.FamilyID)
+		respondWithError(w, http.StatusUnauthorized, "Refresh token has already been used", nil)
+		return
+	}
+
+	if dbRefreshToken.RevokedAt.Valid {
+		respondWithError(w, http.StatusUnauthorized, "Refresh token has been revoked", nil)
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, RefreshResponse{Token: newAccessToken})
+	respondWithError(w, http.StatusUnauthorized, "Refresh token expired", nil)
 }
 
 func (cfg *ApiConfig) revokeRefreshToken(w http.ResponseWriter, req *http.Request) {
@@ -187,7 +335,7 @@ func (cfg *ApiConfig) revokeRefreshToken(w http.ResponseWriter, req *http.Reques
 		return
 	}
 
-	err = cfg.Database.SetRefreshTokenRevokedAt(req.Context(), refreshToken)
+	err = cfg.Database.SetRefreshTokenRevokedAt(req.Context(), auth.HashToken(refreshToken))
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error setting refresh token revoked_at", err)
 		return
@@ -204,15 +352,28 @@ func (cfg *ApiConfig) updateUser(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	userExists, err := authenticate(req, cfg)
+	userExists, err := authenticateSession(req, cfg)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
-    return
+		return
+	}
+
+	err = validateEmail(loginReq.Email)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid email", err)
+		return
+	}
+
+	hashedPassword, ok := cfg.hashNewPassword(w, loginReq.Password, loginReq.Email)
+	if !ok {
+		return
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(loginReq.Password), 4)
+	// the password is always sent along, it only counts as changed if it
+	// does not match the old one
+	samePassword, _, err := cfg.PasswordHasher.Verify(loginReq.Password, userExists.HashedPassword)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Error hashing password from request", err)
+		respondWithError(w, http.StatusInternalServerError, "Error verifying password", err)
 		return
 	}
 
@@ -220,20 +381,153 @@ func (cfg *ApiConfig) updateUser(w http.ResponseWriter, req *http.Request) {
 		req.Context(),
 		database.UpdateUserCredentialsByIdParams{
 			Email:          loginReq.Email,
-			HashedPassword: string(hashedPassword),
+			HashedPassword: hashedPassword,
 			ID:             userExists.ID,
 		})
+	if isUniqueViolation(err, "users_email_key") {
+		respondWithError(w, http.StatusConflict, "Email is already registered", err)
+		return
+	}
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error updating user credentials", err)
 		return
 	}
 
+	// whoever else knew the old credentials is logged out
+	if !samePassword || updatedUser.Email != userExists.Email {
+		err = cfg.logOutEverywhere(req.Context(), updatedUser.ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error revoking tokens", err)
+			return
+		}
+
+		err = cfg.Database.DeleteUserPersonalAccessTokens(req.Context(), updatedUser.ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error revoking personal access tokens", err)
+			return
+		}
+	}
+
+	if updatedUser.Email != userExists.Email {
+		err = cfg.sendVerificationEmail(req.Context(), updatedUser)
+		if err != nil {
+			log.Printf("Error sending verification email to user %v: %v", updatedUser.ID, err)
+		}
+	}
+
 	loginResp := LoginResponse{
 		ID:        updatedUser.ID,
 		CreatedAt: updatedUser.CreatedAt,
 		UpdatedAt: updatedUser.UpdatedAt,
 		Email:     updatedUser.Email,
+		Handle:    updatedUser.Handle,
+		Role:      updatedUser.Role,
 	}
 
 	respondWithJSON(w, http.StatusOK, loginResp)
 }
+
+func (cfg *ApiConfig) deleteUser(w http.ResponseWriter, req *http.Request) {
+	userExists, err := authenticateSession(req, cfg)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return
+	}
+
+	// refresh tokens and personal access tokens stop working right away
+	// instead of when the user is purged
+	tx, err := cfg.DB.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error deleting user from database", err)
+		return
+	}
+	defer tx.Rollback()
+	queries := cfg.Database.WithTx(tx)
+
+	err = queries.SoftDeleteUserByID(req.Context(), database.SoftDeleteUserByIDParams{
+		DeletedAt: time.Now().UTC(),
+		ID:        userExists.ID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error deleting user from database", err)
+		return
+	}
+
+	_, err = queries.RevokeUserSessions(req.Context(), userExists.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error revoking tokens", err)
+		return
+	}
+
+	err = queries.DeleteUserPersonalAccessTokens(req.Context(), userExists.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error revoking personal access tokens", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error deleting user from database", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusNoContent, struct{}{})
+}
+
+// restoreUser lets a deleted user undo the deletion of their account within
+// RestoreWindow by logging in with their old credentials.
+func (cfg *ApiConfig) restoreUser(w http.ResponseWriter, req *http.Request) {
+	loginReq := LoginRequest{}
+	err := decodeRequestBody(&loginReq, req)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error decoding request", err)
+		return
+	}
+
+	deletedUser, err := cfg.Database.GetDeletedUserByEmail(req.Context(), loginReq.Email)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password", err)
+		return
+	}
+
+	match, _, err := cfg.PasswordHasher.Verify(loginReq.Password, deletedUser.HashedPassword)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error verifying password", err)
+		return
+	}
+	if !match {
+		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password", nil)
+		return
+	}
+
+	cfg.respondWithRestoredUser(w, req, deletedUser.ID)
+}
+
+func (cfg *ApiConfig) adminRestoreUser(w http.ResponseWriter, req *http.Request) {
+	id, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Error getting user id from request", err)
+		return
+	}
+
+	cfg.respondWithRestoredUser(w, req, id)
+}
+
+func (cfg *ApiConfig) respondWithRestoredUser(w http.ResponseWriter, req *http.Request, id uuid.UUID) {
+	restoredUser, err := cfg.Database.RestoreUserByID(
+		req.Context(),
+		database.RestoreUserByIDParams{
+			ID:           id,
+			DeletedAfter: time.Now().UTC().Add(-cfg.RestoreWindow),
+		})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "No deleted user within the restore window", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error restoring user in database", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, userToResponse(restoredUser))
+}
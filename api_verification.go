@@ -0,0 +1,62 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/thewerther/webserver/internal/auth"
+	"github.com/thewerther/webserver/internal/database"
+)
+
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+func (cfg *ApiConfig) verifyEmail(w http.ResponseWriter, req *http.Request) {
+	verifyReq := VerifyEmailRequest{}
+	err := decodeRequestBody(&verifyReq, req)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error decoding request", err)
+		return
+	}
+
+	verifiedUser, err := cfg.Database.VerifyEmail(req.Context(), database.VerifyEmailParams{
+		TokenHash: auth.HashToken(verifyReq.Token),
+		Now:       time.Now().UTC(),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusBadRequest, "Invalid or expired verification token", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error verifying email in database", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, userToResponse(verifiedUser))
+}
+
+// resendVerificationEmail sends a new token to the user of the access token,
+// tokens sent before stop working.
+func (cfg *ApiConfig) resendVerificationEmail(w http.ResponseWriter, req *http.Request) {
+	userExists, err := authenticateSession(req, cfg)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return
+	}
+
+	if userExists.EmailVerifiedAt.Valid {
+		respondWithError(w, http.StatusConflict, "Email is already verified", nil)
+		return
+	}
+
+	err = cfg.sendVerificationEmail(req.Context(), userExists)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error sending verification email", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusAccepted, struct{}{})
+}
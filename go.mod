@@ -10,4 +10,6 @@ require github.com/google/uuid v1.6.0
 
 require github.com/lib/pq v1.10.9
 
-require github.com/golang-jwt/jwt/v5 v5.2.1 // indirect
+require github.com/golang-jwt/jwt/v5 v5.2.1
+
+require golang.org/x/sys v0.25.0 // indirect
@@ -2,9 +2,9 @@ package auth
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
-	"fmt"
 	"log"
 	"net/http"
 	"strings"
@@ -14,18 +14,28 @@ import (
 	"github.com/google/uuid"
 )
 
-func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
+// Claims are the claims of chirpy access tokens.
+type Claims struct {
+	jwt.RegisteredClaims
+	Role string `json:"role,omitempty"`
+}
+
+func MakeJWT(userID uuid.UUID, role string, keys *KeySet, expiresIn time.Duration) (string, error) {
 	timeNow := time.Now().UTC()
 	expiresAt := timeNow.Add(expiresIn)
   log.Printf("Expire time for jwt token set to %v\n", expiresAt)
-	claims := jwt.RegisteredClaims{
-		Issuer:    "chirpy",
-		IssuedAt:  jwt.NewNumericDate(timeNow),
-		ExpiresAt: jwt.NewNumericDate(expiresAt),
-		Subject:   userID.String(),
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "chirpy",
+			IssuedAt:  jwt.NewNumericDate(timeNow),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			Subject:   userID.String(),
+			// lets single tokens be revoked before they expire
+			ID: uuid.NewString(),
+		},
+		Role: role,
 	}
-	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := jwtToken.SignedString([]byte(tokenSecret))
+	signedToken, err := keys.sign(claims)
 	if err != nil {
 		return "", err
 	}
@@ -33,23 +43,34 @@ func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (str
 	return signedToken, nil
 }
 
-func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(t *jwt.Token) (interface{}, error) {
-    if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-      return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
-    }
-		return []byte(tokenSecret), nil
-	})
+func ValidateJWT(tokenString string, keys *KeySet) (uuid.UUID, error) {
+	claims, err := ParseJWT(tokenString, keys)
 	if err != nil {
 		return uuid.UUID{}, err
 	}
 
-  claims, ok := token.Claims.(*jwt.RegisteredClaims)
+	return claims.UserID()
+}
+
+// ParseJWT validates a token with the key named by its `kid` header and
+// returns all of its claims.
+func ParseJWT(tokenString string, keys *KeySet) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, keys.keyFunc)
+	if err != nil {
+		return nil, err
+	}
+
+  claims, ok := token.Claims.(*Claims)
 	if !ok {
-    return uuid.UUID{}, errors.New("Unable to extract claims from token!")
+    return nil, errors.New("Unable to extract claims from token!")
   }
 
-  subject, err := claims.GetSubject()
+	return claims, nil
+}
+
+// UserID parses the subject of the token.
+func (c *Claims) UserID() (uuid.UUID, error) {
+  subject, err := c.GetSubject()
   if err != nil {
     return uuid.UUID{}, err
   }
@@ -104,3 +125,11 @@ func GetAPIKey(headers http.Header) (string, error) {
 
    return authAPIKey, nil
 }
+
+// HashToken returns the hex encoded SHA-256 of a random token so tokens can be
+// looked up without storing them in plain text. Unlike passwords, tokens have
+// enough entropy that a fast hash is fine.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
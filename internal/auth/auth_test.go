@@ -10,8 +10,17 @@ import (
 
 const TEST_SECRET = "gsRBlZzXgD9nvGCWX0ba/iiIE0z/kNoa/67lv74Z50oKY6TcX/NURSb9BF+G+VoZWnLS5F7QPEbSRiayUGyMUQ=="
 
+func testKeySet(secret string) *KeySet {
+	keys, err := NewHMACKeySet(secret)
+	if err != nil {
+		panic(err)
+	}
+
+	return keys
+}
+
 func createTestToken(id uuid.UUID) (string, error) {
-	token, err := MakeJWT(id, TEST_SECRET, 10 * time.Second)
+	token, err := MakeJWT(id, "user", testKeySet(TEST_SECRET), 10 * time.Second)
 	if err != nil {
 		return "", err
 	}
@@ -27,7 +36,7 @@ func TestValidJWT(t *testing.T) {
 		return
 	}
 
-	returnedID, err := ValidateJWT(token, TEST_SECRET)
+	returnedID, err := ValidateJWT(token, testKeySet(TEST_SECRET))
 	if err != nil {
     t.Errorf("Test ValidJWT::ValidateJWT failed with err: %v", err)
 		return
@@ -47,7 +56,7 @@ func TestInvalidJWT(t *testing.T) {
 		return
 	}
 
-	returnedID, err := ValidateJWT(token, otherSecret)
+	returnedID, err := ValidateJWT(token, testKeySet(otherSecret))
 	if err == nil {
     t.Errorf("Test InvalidJWT::ValidateJWT failed with err: %v", err)
 	}
@@ -71,7 +80,7 @@ func TestValidBearerToken(t *testing.T) {
     t.Errorf("Test ValidBearerToken failed with err: %v", err)
   }
 
-  returnedId, err := ValidateJWT(token, TEST_SECRET)
+  returnedId, err := ValidateJWT(token, testKeySet(TEST_SECRET))
   if err != nil {
     t.Errorf("Test ValidBearerToken failed with err: %v", err)
   }
@@ -91,3 +100,43 @@ func TestMakeRefreshToken(t *testing.T) {
     t.Errorf("Test MakeRefreshToken failed because token length is not 32, actual length: %v", len(token))
   }
 }
+
+func TestRoleClaim(t *testing.T) {
+	id := uuid.New()
+	token, err := MakeJWT(id, "moderator", testKeySet(TEST_SECRET), 10*time.Second)
+	if err != nil {
+		t.Errorf("Test RoleClaim::MakeJWT failed with err: %v", err)
+		return
+	}
+
+	claims, err := ParseJWT(token, testKeySet(TEST_SECRET))
+	if err != nil {
+		t.Errorf("Test RoleClaim::ParseJWT failed with err: %v", err)
+		return
+	}
+
+	if claims.Role != "moderator" {
+		t.Errorf("Test RoleClaim failed: expected role: moderator, got: %v", claims.Role)
+	}
+
+	returnedID, err := claims.UserID()
+	if err != nil || returnedID != id {
+		t.Errorf("Test RoleClaim failed: expected ID: %v, got: %v, err: %v", id, returnedID, err)
+	}
+}
+
+func TestHashToken(t *testing.T) {
+	token, err := MakeRefreshToken()
+	if err != nil {
+		t.Fatalf("Test HashToken::MakeRefreshToken failed with err: %v", err)
+	}
+
+	hash := HashToken(token)
+	if hash == token || len(hash) != 64 {
+		t.Errorf("Test HashToken failed: unexpected hash %q", hash)
+	}
+
+	if HashToken(token) != hash {
+		t.Errorf("Test HashToken failed: hashing the same token twice gave different results")
+	}
+}
@@ -0,0 +1,261 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// RSA keys shorter than this are rejected
+const minRSAKeyBits = 2048
+
+// SigningKey is one key of a KeySet. Keys loaded from a public key file can
+// only verify tokens, which is how retired keys stay valid until the tokens
+// signed with them expire.
+type SigningKey struct {
+	ID     string
+	Method jwt.SigningMethod
+	// *rsa.PrivateKey, ed25519.PrivateKey or []byte for HMAC, nil if the key
+	// can only verify
+	signKey any
+	// *rsa.PublicKey, ed25519.PublicKey or []byte for HMAC
+	verifyKey any
+}
+
+// KeySet holds all keys access tokens may be signed with. New tokens are
+// signed with the current key and carry its ID as `kid` header, so tokens
+// signed with an older key still validate during a rotation.
+type KeySet struct {
+	keys    map[string]*SigningKey
+	current *SigningKey
+}
+
+// NewHMACKeySet returns a key set with a single HS256 secret.
+func NewHMACKeySet(secret string) (*KeySet, error) {
+	if secret == "" {
+		return nil, errors.New("auth: empty HMAC secret")
+	}
+
+	// the kid must not leak anything about the secret, a short hash of it
+	// only changes when the secret does
+	sum := sha256.Sum256([]byte("kid:" + secret))
+	key := &SigningKey{
+		ID:        "hs256-" + hex.EncodeToString(sum[:4]),
+		Method:    jwt.SigningMethodHS256,
+		signKey:   []byte(secret),
+		verifyKey: []byte(secret),
+	}
+
+	return &KeySet{
+		keys:    map[string]*SigningKey{key.ID: key},
+		current: key,
+	}, nil
+}
+
+// LoadKeySet reads all *.pem files of dir, the file name without extension is
+// the key ID. Private keys can be RSA (RS256) or Ed25519 (EdDSA) in PKCS#8 or
+// PKCS#1 format, public keys in PKIX format are only used to verify tokens.
+// currentID picks the key new tokens are signed with, if it is empty the
+// private key with the last ID in lexical order is used, so naming keys by
+// date rotates them by adding a file.
+func LoadKeySet(dir, currentID string) (*KeySet, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	keySet := &KeySet{keys: map[string]*SigningKey{}}
+	for _, path := range paths {
+		id := strings.TrimSuffix(filepath.Base(path), ".pem")
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := parseSigningKey(id, data)
+		if err != nil {
+			return nil, fmt.Errorf("auth: key %s: %w", path, err)
+		}
+		keySet.keys[id] = key
+
+		if key.signKey != nil && currentID == "" {
+			keySet.current = key
+		}
+	}
+
+	if currentID != "" {
+		key, ok := keySet.keys[currentID]
+		if !ok || key.signKey == nil {
+			return nil, fmt.Errorf("auth: no private key with id %q in %s", currentID, dir)
+		}
+		keySet.current = key
+	}
+
+	if keySet.current == nil {
+		return nil, fmt.Errorf("auth: no private key in %s", dir)
+	}
+
+	return keySet, nil
+}
+
+func parseSigningKey(id string, data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := parsed.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type %T", parsed)
+		}
+		return newSigningKey(id, signer, signer.Public())
+	case "RSA PRIVATE KEY":
+		parsed, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return newSigningKey(id, parsed, parsed.Public())
+	case "PUBLIC KEY":
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return newSigningKey(id, nil, parsed)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+}
+
+func newSigningKey(id string, private crypto.Signer, public crypto.PublicKey) (*SigningKey, error) {
+	key := &SigningKey{ID: id, verifyKey: public}
+
+	switch public := public.(type) {
+	case *rsa.PublicKey:
+		if public.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("RSA key has %d bits, at least %d are required", public.N.BitLen(), minRSAKeyBits)
+		}
+		key.Method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.Method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", public)
+	}
+
+	// an interface holding a nil pointer is not nil
+	if private != nil {
+		key.signKey = private
+	}
+
+	return key, nil
+}
+
+// sign signs claims with the current key.
+func (k *KeySet) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.current.Method, claims)
+	token.Header["kid"] = k.current.ID
+
+	return token.SignedString(k.current.signKey)
+}
+
+// keyFunc selects the key by the `kid` header. Tokens without one are only
+// accepted by a key set with a single HMAC key, those were issued before key
+// IDs existed.
+func (k *KeySet) keyFunc(t *jwt.Token) (interface{}, error) {
+	key := k.current
+	kid, hasKid := t.Header["kid"].(string)
+	if hasKid {
+		var ok bool
+		key, ok = k.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+	} else if len(k.keys) != 1 || key.Method != jwt.SigningMethodHS256 {
+		return nil, errors.New("token has no key id")
+	}
+
+	// the algorithm has to match the key, otherwise a public key could be
+	// used as HMAC secret
+	if t.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+	}
+
+	return key.verifyKey, nil
+}
+
+// JWK is a public key in the JSON Web Key format of RFC 7517.
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys of the set so other services can validate
+// access tokens. HMAC secrets are never included.
+func (k *KeySet) JWKS() JWKS {
+	ids := []string{}
+	for id := range k.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	jwks := JWKS{Keys: []JWK{}}
+	for _, id := range ids {
+		key := k.keys[id]
+
+		switch public := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			jwks.Keys = append(jwks.Keys, JWK{
+				Kty: "RSA",
+				Use: "sig",
+				Alg: key.Method.Alg(),
+				Kid: key.ID,
+				N:   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			jwks.Keys = append(jwks.Keys, JWK{
+				Kty: "OKP",
+				Use: "sig",
+				Alg: key.Method.Alg(),
+				Kid: key.ID,
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(public),
+			})
+		}
+	}
+
+	return jwks
+}
@@ -0,0 +1,220 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	err := os.WriteFile(path, data, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writePrivateKey(t *testing.T, dir, id string, key any) {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, filepath.Join(dir, id+".pem"), "PRIVATE KEY", der)
+}
+
+func TestKeySetRotation(t *testing.T) {
+	dir := t.TempDir()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePrivateKey(t, dir, "2026-01", rsaKey)
+
+	oldKeys, err := LoadKeySet(dir, "")
+	if err != nil {
+		t.Fatalf("Test KeySetRotation::LoadKeySet failed with err: %v", err)
+	}
+
+	id := uuid.New()
+	oldToken, err := MakeJWT(id, "user", oldKeys, time.Minute)
+	if err != nil {
+		t.Fatalf("Test KeySetRotation::MakeJWT failed with err: %v", err)
+	}
+
+	// a newer key is added, the old one stays to validate tokens signed with it
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePrivateKey(t, dir, "2026-02", edKey)
+
+	newKeys, err := LoadKeySet(dir, "")
+	if err != nil {
+		t.Fatalf("Test KeySetRotation::LoadKeySet failed with err: %v", err)
+	}
+
+	newToken, err := MakeJWT(id, "user", newKeys, time.Minute)
+	if err != nil {
+		t.Fatalf("Test KeySetRotation::MakeJWT failed with err: %v", err)
+	}
+
+	parsed, _, err := jwt.NewParser().ParseUnverified(newToken, &Claims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Header["kid"] != "2026-02" || parsed.Method.Alg() != "EdDSA" {
+		t.Errorf("Test KeySetRotation failed: new token signed with kid %v and alg %v", parsed.Header["kid"], parsed.Method.Alg())
+	}
+
+	for _, token := range []string{oldToken, newToken} {
+		returnedID, err := ValidateJWT(token, newKeys)
+		if err != nil || returnedID != id {
+			t.Errorf("Test KeySetRotation::ValidateJWT failed: expected ID: %v, got: %v, err: %v", id, returnedID, err)
+		}
+	}
+
+	// tokens of the new key are unknown to the old key set
+	_, err = ValidateJWT(newToken, oldKeys)
+	if err == nil {
+		t.Errorf("Test KeySetRotation failed: token with unknown kid accepted")
+	}
+}
+
+func TestKeySetPublicKeyOnly(t *testing.T) {
+	dir := t.TempDir()
+
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, filepath.Join(dir, "retired.pem"), "PUBLIC KEY", der)
+
+	_, err = LoadKeySet(dir, "")
+	if err == nil {
+		t.Errorf("Test KeySetPublicKeyOnly failed: key set without private key accepted")
+	}
+
+	_, err = LoadKeySet(dir, "retired")
+	if err == nil {
+		t.Errorf("Test KeySetPublicKeyOnly failed: public key accepted as current key")
+	}
+
+	writePrivateKey(t, dir, "current", private)
+	keys, err := LoadKeySet(dir, "current")
+	if err != nil {
+		t.Fatalf("Test KeySetPublicKeyOnly::LoadKeySet failed with err: %v", err)
+	}
+	if len(keys.JWKS().Keys) != 2 {
+		t.Errorf("Test KeySetPublicKeyOnly failed: expected 2 keys in JWKS, got %d", len(keys.JWKS().Keys))
+	}
+}
+
+func TestKeySetRejectsAlgorithmConfusion(t *testing.T) {
+	dir := t.TempDir()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePrivateKey(t, dir, "rsa", rsaKey)
+
+	keys, err := LoadKeySet(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an HS256 token using the public key as secret
+	publicDER, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: uuid.NewString()},
+	})
+	forged.Header["kid"] = "rsa"
+	token, err := forged.SignedString(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ValidateJWT(token, keys)
+	if err == nil {
+		t.Errorf("Test KeySetRejectsAlgorithmConfusion failed: HS256 token accepted for RSA key")
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	dir := t.TempDir()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, filepath.Join(dir, "a.pem"), "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePrivateKey(t, dir, "b", private)
+
+	keys, err := LoadKeySet(dir, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwks := keys.JWKS()
+	if len(jwks.Keys) != 2 {
+		t.Fatalf("Test JWKS failed: expected 2 keys, got %d", len(jwks.Keys))
+	}
+
+	rsaJWK := jwks.Keys[0]
+	if rsaJWK.Kid != "a" || rsaJWK.Kty != "RSA" || rsaJWK.Alg != "RS256" || rsaJWK.E != "AQAB" || rsaJWK.N == "" {
+		t.Errorf("Test JWKS failed: unexpected RSA key %+v", rsaJWK)
+	}
+
+	edJWK := jwks.Keys[1]
+	if edJWK.Kid != "b" || edJWK.Kty != "OKP" || edJWK.Crv != "Ed25519" || len(edJWK.X) != 43 {
+		t.Errorf("Test JWKS failed: unexpected Ed25519 key %+v", edJWK)
+	}
+
+	hmacKeys := testKeySet(TEST_SECRET)
+	if len(hmacKeys.JWKS().Keys) != 0 {
+		t.Errorf("Test JWKS failed: HMAC secret published")
+	}
+}
+
+func TestHMACKeySetAcceptsTokensWithoutKid(t *testing.T) {
+	id := uuid.New()
+	legacy := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   id.String(),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	})
+	token, err := legacy.SignedString([]byte(TEST_SECRET))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	returnedID, err := ValidateJWT(token, testKeySet(TEST_SECRET))
+	if err != nil || returnedID != id {
+		t.Errorf("Test HMACKeySetAcceptsTokensWithoutKid failed: expected ID: %v, got: %v, err: %v", id, returnedID, err)
+	}
+}
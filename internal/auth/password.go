@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrUnknownHashFormat = errors.New("auth: unknown password hash format")
+
+// MaxPasswordBytes is the longest password bcrypt works with. New passwords
+// are kept within it so they can still be checked against legacy hashes.
+const MaxPasswordBytes = 72
+
+// PasswordHasher hashes passwords for storage and checks passwords against
+// stored hashes.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	// Verify reports whether password matches hash. needsRehash is true if
+	// hash was made with another algorithm or other parameters than the ones
+	// Hash uses now, so it should be replaced after a successful login.
+	Verify(password, hash string) (match bool, needsRehash bool, err error)
+}
+
+// Argon2idParams configure the cost of argon2id, Memory is in KiB.
+type Argon2idParams struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2idParams follows the OWASP recommendation of 19 MiB of memory
+// and 2 iterations.
+func DefaultArgon2idParams() Argon2idParams {
+	return Argon2idParams{
+		Memory:      19 * 1024,
+		Iterations:  2,
+		Parallelism: 1,
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+}
+
+// Argon2idHasher stores hashes in the PHC string format, e.g.
+//
+//	$argon2id$v=19$m=19456,t=2,p=1$<salt>$<key>
+//
+// It still verifies bcrypt hashes created before argon2id was introduced.
+type Argon2idHasher struct {
+	Params Argon2idParams
+}
+
+func NewArgon2idHasher(params Argon2idParams) *Argon2idHasher {
+	return &Argon2idHasher{Params: params}
+}
+
+func (h *Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.Params.SaltLength)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.Params.Iterations, h.Params.Memory, h.Params.Parallelism, h.Params.KeyLength)
+
+	return fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		h.Params.Memory,
+		h.Params.Iterations,
+		h.Params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h *Argon2idHasher) Verify(password, hash string) (bool, bool, error) {
+	// users who signed up with an identity provider have no password
+	if hash == "" {
+		return false, false, nil
+	}
+
+	if isBcryptHash(hash) {
+		// bcrypt never hashed longer passwords, so they can not match
+		if len(password) > MaxPasswordBytes {
+			return false, false, nil
+		}
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, false, nil
+		}
+		if err != nil {
+			return false, false, err
+		}
+		return true, true, nil
+	}
+
+	params, salt, key, err := decodeArgon2idHash(hash)
+	if err != nil {
+		return false, false, err
+	}
+
+	actual := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+	if subtle.ConstantTimeCompare(actual, key) != 1 {
+		return false, false, nil
+	}
+
+	needsRehash := params.Memory != h.Params.Memory ||
+		params.Iterations != h.Params.Iterations ||
+		params.Parallelism != h.Params.Parallelism ||
+		params.SaltLength != h.Params.SaltLength ||
+		params.KeyLength != h.Params.KeyLength
+
+	return true, needsRehash, nil
+}
+
+func isBcryptHash(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+func decodeArgon2idHash(hash string) (Argon2idParams, []byte, []byte, error) {
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != "argon2id" {
+		return Argon2idParams{}, nil, nil, ErrUnknownHashFormat
+	}
+
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return Argon2idParams{}, nil, nil, ErrUnknownHashFormat
+	}
+
+	params := Argon2idParams{}
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism)
+	if err != nil {
+		return Argon2idParams{}, nil, nil, ErrUnknownHashFormat
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2idParams{}, nil, nil, ErrUnknownHashFormat
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return Argon2idParams{}, nil, nil, ErrUnknownHashFormat
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+
+	return params, salt, key, nil
+}
@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// cheap parameters keep the tests fast
+var testParams = Argon2idParams{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestArgon2idHashAndVerify(t *testing.T) {
+	hasher := NewArgon2idHasher(testParams)
+
+	hash, err := hasher.Hash("correct horse")
+	if err != nil {
+		t.Fatalf("Test Argon2id::Hash failed with err: %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Errorf("Test Argon2id::Hash failed: unexpected format %q", hash)
+	}
+
+	match, needsRehash, err := hasher.Verify("correct horse", hash)
+	if err != nil || !match || needsRehash {
+		t.Errorf("Test Argon2id::Verify failed: match: %v, needsRehash: %v, err: %v", match, needsRehash, err)
+	}
+
+	match, _, err = hasher.Verify("wrong horse", hash)
+	if err != nil || match {
+		t.Errorf("Test Argon2id::Verify failed: wrong password matched, err: %v", err)
+	}
+}
+
+func TestArgon2idRehash(t *testing.T) {
+	hash, err := NewArgon2idHasher(testParams).Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stronger := testParams
+	stronger.Iterations = 2
+	match, needsRehash, err := NewArgon2idHasher(stronger).Verify("correct horse", hash)
+	if err != nil || !match || !needsRehash {
+		t.Errorf("Test Argon2idRehash failed: match: %v, needsRehash: %v, err: %v", match, needsRehash, err)
+	}
+}
+
+func TestVerifyLegacyBcrypt(t *testing.T) {
+	legacy, err := bcrypt.GenerateFromPassword([]byte("correct horse"), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hasher := NewArgon2idHasher(testParams)
+
+	match, needsRehash, err := hasher.Verify("correct horse", string(legacy))
+	if err != nil || !match || !needsRehash {
+		t.Errorf("Test VerifyLegacyBcrypt failed: match: %v, needsRehash: %v, err: %v", match, needsRehash, err)
+	}
+
+	match, _, err = hasher.Verify("wrong horse", string(legacy))
+	if err != nil || match {
+		t.Errorf("Test VerifyLegacyBcrypt failed: wrong password matched, err: %v", err)
+	}
+}
+
+func TestVerifyLegacyBcryptLongPassword(t *testing.T) {
+	password := strings.Repeat("a", MaxPasswordBytes)
+	legacy, err := bcrypt.GenerateFromPassword([]byte(password), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hasher := NewArgon2idHasher(testParams)
+
+	// bcrypt would only look at the first 72 bytes and match
+	match, _, err := hasher.Verify(password+"b", string(legacy))
+	if err != nil || match {
+		t.Errorf("Test VerifyLegacyBcryptLongPassword failed: match: %v, err: %v", match, err)
+	}
+}
+
+func TestVerifyUnknownFormat(t *testing.T) {
+	_, _, err := NewArgon2idHasher(testParams).Verify("password", "plaintext")
+	if !errors.Is(err, ErrUnknownHashFormat) {
+		t.Errorf("Test VerifyUnknownFormat failed: expected ErrUnknownHashFormat, got %v", err)
+	}
+}
+
+func TestVerifyWithoutPassword(t *testing.T) {
+	match, _, err := NewArgon2idHasher(testParams).Verify("", "")
+	if err != nil || match {
+		t.Errorf("Test VerifyWithoutPassword failed: match %v, err: %v", match, err)
+	}
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	// "password123" in plain text and "letmein123" as a SHA-1 hash
+	content := "password123\n" + sha1Hex("letmein123") + ":42\n"
+	err := os.WriteFile(path, []byte(content), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	policy := DefaultPasswordPolicy()
+	err = policy.LoadBreachedPasswords(path)
+	if err != nil {
+		t.Fatalf("Test PasswordPolicy::LoadBreachedPasswords failed with err: %v", err)
+	}
+
+	cases := []struct {
+		password string
+		codes    []string
+	}{
+		{password: "a long enough password", codes: nil},
+		{password: "short", codes: []string{"too_short"}},
+		{password: strings.Repeat("a", 129), codes: []string{"too_long"}},
+		// 80 characters but 160 bytes
+		{password: strings.Repeat("ä", 80), codes: []string{"too_long"}},
+		{password: "password123", codes: []string{"breached"}},
+		{password: "letmein123", codes: []string{"breached"}},
+		{password: "User@Example.com", codes: []string{"same_as_email"}},
+	}
+
+	for _, c := range cases {
+		err := policy.Check(c.password, "user@example.com")
+
+		codes := []string{}
+		policyErr := &PolicyError{}
+		if errors.As(err, &policyErr) {
+			for _, violation := range policyErr.Violations {
+				codes = append(codes, violation.Code)
+			}
+		} else if err != nil {
+			t.Errorf("Test PasswordPolicy(%q) failed with unexpected err: %v", c.password, err)
+		}
+
+		if strings.Join(codes, ",") != strings.Join(c.codes, ",") {
+			t.Errorf("Test PasswordPolicy(%q) failed: expected %v, got %v", c.password, c.codes, codes)
+		}
+	}
+}
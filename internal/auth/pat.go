@@ -0,0 +1,22 @@
+package auth
+
+import "strings"
+
+// PersonalAccessTokenPrefix tells personal access tokens apart from JWTs and
+// makes them easy to find for secret scanners.
+const PersonalAccessTokenPrefix = "chirpy_pat_"
+
+// MakePersonalAccessToken returns a new random personal access token. Like
+// refresh tokens it is only stored as HashToken.
+func MakePersonalAccessToken() (string, error) {
+	token, err := MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+
+	return PersonalAccessTokenPrefix + token, nil
+}
+
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, PersonalAccessTokenPrefix)
+}
@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestPersonalAccessToken(t *testing.T) {
+	token, err := MakePersonalAccessToken()
+	if err != nil {
+		t.Fatalf("Test PersonalAccessToken::MakePersonalAccessToken failed with err: %v", err)
+	}
+
+	if !IsPersonalAccessToken(token) {
+		t.Errorf("Test PersonalAccessToken failed: %q is not recognized", token)
+	}
+
+	other, err := MakePersonalAccessToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token == other {
+		t.Errorf("Test PersonalAccessToken failed: tokens are not random")
+	}
+
+	jwt, err := MakeJWT(uuid.New(), "user", testKeySet("secret"), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if IsPersonalAccessToken(jwt) {
+		t.Errorf("Test PersonalAccessToken failed: JWT taken for a personal access token")
+	}
+}
@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// PolicyViolation is one reason a password was rejected, Code is stable and
+// meant for clients while Message is meant for humans.
+type PolicyViolation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// PolicyError lists all reasons a password was rejected at once.
+type PolicyError struct {
+	Violations []PolicyViolation
+}
+
+func (e *PolicyError) Error() string {
+	messages := []string{}
+	for _, violation := range e.Violations {
+		messages = append(messages, violation.Message)
+	}
+	return "password does not meet the policy: " + strings.Join(messages, ", ")
+}
+
+// PasswordPolicy decides which new passwords are accepted. Lengths are
+// counted in characters, independent of them passwords can not be longer than
+// MaxPasswordBytes.
+type PasswordPolicy struct {
+	MinLength int
+	MaxLength int
+	// SHA-1 hashes of breached passwords, upper case hex
+	breached map[string]struct{}
+}
+
+func DefaultPasswordPolicy() *PasswordPolicy {
+	return &PasswordPolicy{MinLength: 8, MaxLength: 128}
+}
+
+var sha1Pattern = regexp.MustCompile(`^[0-9A-Fa-f]{40}(:\d+)?$`)
+
+// LoadBreachedPasswords reads a list of passwords that must not be used, one
+// per line. Lines can either be the password itself or its SHA-1 hash in hex,
+// optionally followed by `:count` like in the Have I Been Pwned downloads.
+func (p *PasswordPolicy) LoadBreachedPasswords(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	breached := map[string]struct{}{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+
+		if sha1Pattern.MatchString(line) {
+			breached[strings.ToUpper(line[:40])] = struct{}{}
+			continue
+		}
+		breached[sha1Hex(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	p.breached = breached
+	return nil
+}
+
+func sha1Hex(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// Check returns a *PolicyError if password is not acceptable. email is the
+// address of the account the password is for.
+func (p *PasswordPolicy) Check(password, email string) error {
+	violations := []PolicyViolation{}
+
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		violations = append(violations, PolicyViolation{
+			Code:    "too_short",
+			Message: fmt.Sprintf("password has to be at least %d characters long", p.MinLength),
+		})
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		violations = append(violations, PolicyViolation{
+			Code:    "too_long",
+			Message: fmt.Sprintf("password can be at most %d characters long", p.MaxLength),
+		})
+	} else if len(password) > MaxPasswordBytes {
+		violations = append(violations, PolicyViolation{
+			Code:    "too_long",
+			Message: fmt.Sprintf("password can be at most %d bytes long", MaxPasswordBytes),
+		})
+	}
+
+	if email != "" && strings.EqualFold(password, email) {
+		violations = append(violations, PolicyViolation{
+			Code:    "same_as_email",
+			Message: "password can not be the email address",
+		})
+	}
+
+	if _, breached := p.breached[sha1Hex(password)]; breached {
+		violations = append(violations, PolicyViolation{
+			Code:    "breached",
+			Message: "password has appeared in a data breach, pick another one",
+		})
+	}
+
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RevocationList is the in-memory view of revoked access tokens, checked on
+// every request. Single tokens are revoked by their `jti` until they expire,
+// all tokens of a user by the time before which they have been issued.
+type RevocationList struct {
+	mu sync.RWMutex
+	// jti -> expiry of the token
+	tokens map[string]time.Time
+	// user -> tokens issued before are revoked
+	users map[uuid.UUID]time.Time
+}
+
+func NewRevocationList() *RevocationList {
+	return &RevocationList{
+		tokens: map[string]time.Time{},
+		users:  map[uuid.UUID]time.Time{},
+	}
+}
+
+func (r *RevocationList) RevokeToken(jti string, expiresAt time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.tokens[jti] = expiresAt
+}
+
+// RevokeUserTokens revokes all tokens of a user issued before the given time.
+// Earlier cutoffs never replace later ones.
+func (r *RevocationList) RevokeUserTokens(userID uuid.UUID, before time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if before.After(r.users[userID]) {
+		r.users[userID] = before
+	}
+}
+
+// IsRevoked reports whether the token has been revoked. `iat` only has
+// second precision, so a token with the same `iat` as the cutoff may have been
+// issued before it. The cutoff is rounded up to the next second and tokens
+// issued until then are revoked as well, logging in right after logging out
+// everywhere has to wait for that second to pass.
+func (r *RevocationList) IsRevoked(claims *Claims) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if claims.ID != "" {
+		if _, revoked := r.tokens[claims.ID]; revoked {
+			return true
+		}
+	}
+
+	userID, err := claims.UserID()
+	if err != nil {
+		return true
+	}
+
+	cutoff, ok := r.users[userID]
+	if !ok {
+		return false
+	}
+
+	revokedUntil := cutoff.Truncate(time.Second)
+	if revokedUntil.Before(cutoff) {
+		revokedUntil = revokedUntil.Add(time.Second)
+	}
+
+	return claims.IssuedAt == nil || !claims.IssuedAt.Time.After(revokedUntil)
+}
+
+// Prune forgets tokens that expired and user cutoffs older than maxTokenAge,
+// since every token they would revoke has expired by now.
+func (r *RevocationList) Prune(now time.Time, maxTokenAge time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for jti, expiresAt := range r.tokens {
+		if expiresAt.Before(now) {
+			delete(r.tokens, jti)
+		}
+	}
+
+	for userID, cutoff := range r.users {
+		if cutoff.Before(now.Add(-maxTokenAge)) {
+			delete(r.users, userID)
+		}
+	}
+}
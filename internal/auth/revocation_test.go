@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func testClaims(userID uuid.UUID, jti string, issuedAt time.Time) *Claims {
+	return &Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Subject:   userID.String(),
+			IssuedAt:  jwt.NewNumericDate(issuedAt),
+			ExpiresAt: jwt.NewNumericDate(issuedAt.Add(time.Hour)),
+		},
+	}
+}
+
+func TestRevokeToken(t *testing.T) {
+	list := NewRevocationList()
+	now := time.Now()
+	userID := uuid.New()
+
+	list.RevokeToken("revoked", now.Add(time.Hour))
+
+	if !list.IsRevoked(testClaims(userID, "revoked", now)) {
+		t.Errorf("Test RevokeToken failed: revoked token accepted")
+	}
+	if list.IsRevoked(testClaims(userID, "other", now)) {
+		t.Errorf("Test RevokeToken failed: other token of the same user rejected")
+	}
+
+	list.Prune(now.Add(2*time.Hour), time.Hour)
+	if list.IsRevoked(testClaims(userID, "revoked", now)) {
+		t.Errorf("Test RevokeToken failed: expired revocation not pruned")
+	}
+}
+
+func TestRevokeUserTokens(t *testing.T) {
+	list := NewRevocationList()
+	cutoff := time.Date(2026, 1, 1, 12, 0, 0, 500_000_000, time.UTC)
+	userID := uuid.New()
+
+	list.RevokeUserTokens(userID, cutoff)
+	// an older cutoff, e.g. from the database sync, does not undo a newer one
+	list.RevokeUserTokens(userID, cutoff.Add(-time.Minute))
+
+	cases := []struct {
+		issuedAt time.Time
+		revoked  bool
+	}{
+		{issuedAt: cutoff.Add(-time.Second), revoked: true},
+		{issuedAt: cutoff.Add(-30 * time.Second), revoked: true},
+		// same second as the cutoff, the token may be older than it
+		{issuedAt: cutoff.Add(-time.Millisecond), revoked: true},
+		{issuedAt: cutoff, revoked: true},
+		// the second the cutoff is rounded up to
+		{issuedAt: cutoff.Add(time.Second), revoked: true},
+		{issuedAt: cutoff.Add(2 * time.Second), revoked: false},
+	}
+
+	for _, c := range cases {
+		revoked := list.IsRevoked(testClaims(userID, uuid.NewString(), c.issuedAt))
+		if revoked != c.revoked {
+			t.Errorf("Test RevokeUserTokens(%v) failed: expected revoked: %v, got: %v", c.issuedAt, c.revoked, revoked)
+		}
+	}
+
+	if list.IsRevoked(testClaims(uuid.New(), uuid.NewString(), cutoff.Add(-time.Second))) {
+		t.Errorf("Test RevokeUserTokens failed: token of another user rejected")
+	}
+
+	list.Prune(cutoff.Add(2*time.Hour), time.Hour)
+	if list.IsRevoked(testClaims(userID, uuid.NewString(), cutoff.Add(-time.Second))) {
+		t.Errorf("Test RevokeUserTokens failed: old cutoff not pruned")
+	}
+}
+
+func TestRevokeUserTokensSameSecond(t *testing.T) {
+	list := NewRevocationList()
+	userID := uuid.New()
+	issuedAt := time.Date(2026, 1, 1, 12, 0, 0, 100_000_000, time.UTC)
+
+	// the token was issued before logging out everywhere in the same second
+	list.RevokeUserTokens(userID, issuedAt.Add(800*time.Millisecond))
+	if !list.IsRevoked(testClaims(userID, uuid.NewString(), issuedAt)) {
+		t.Errorf("Test RevokeUserTokensSameSecond failed: token issued before the cutoff is still valid")
+	}
+
+	// a cutoff on a whole second is not rounded up
+	otherUserID := uuid.New()
+	cutoff := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	list.RevokeUserTokens(otherUserID, cutoff)
+	if !list.IsRevoked(testClaims(otherUserID, uuid.NewString(), cutoff)) {
+		t.Errorf("Test RevokeUserTokensSameSecond failed: token issued at the cutoff is still valid")
+	}
+	if list.IsRevoked(testClaims(otherUserID, uuid.NewString(), cutoff.Add(time.Second))) {
+		t.Errorf("Test RevokeUserTokensSameSecond failed: token issued after the cutoff rejected")
+	}
+}
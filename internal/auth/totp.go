@@ -0,0 +1,157 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TOTP as described in RFC 6238 with the defaults authenticator apps expect:
+// SHA-1, 6 digits and 30 second steps.
+const (
+	totpDigits = 6
+	totpPeriod = 30 * time.Second
+	// codes of the previous and next step are accepted as well to allow for
+	// clocks being slightly off
+	totpSkew = 1
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTOTPSecret returns a random 160 bit secret in base32, the encoding
+// authenticator apps use.
+func GenerateTOTPSecret() (string, error) {
+	secret := make([]byte, 20)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return "", err
+	}
+
+	return totpEncoding.EncodeToString(secret), nil
+}
+
+// TOTPURI returns the otpauth:// URI that authenticator apps read, usually
+// from a QR code.
+func TOTPURI(issuer, account, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(totpDigits))
+	query.Set("period", fmt.Sprint(int(totpPeriod.Seconds())))
+
+	label := url.PathEscape(issuer + ":" + account)
+
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// TOTPCode returns the code for the time step t falls into.
+func TOTPCode(secret string, t time.Time) (string, error) {
+	key, err := decodeTOTPSecret(secret)
+	if err != nil {
+		return "", err
+	}
+
+	return hotp(key, totpStep(t)), nil
+}
+
+// ValidateTOTP checks code against the steps around t. It returns the step the
+// code belongs to so callers can refuse to accept a code twice.
+func ValidateTOTP(secret, code string, t time.Time) (int64, bool, error) {
+	key, err := decodeTOTPSecret(secret)
+	if err != nil {
+		return 0, false, err
+	}
+
+	code = strings.TrimSpace(code)
+	current := totpStep(t)
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if subtle.ConstantTimeCompare([]byte(hotp(key, step)), []byte(code)) == 1 {
+			return step, true, nil
+		}
+	}
+
+	return 0, false, nil
+}
+
+func totpStep(t time.Time) int64 {
+	return t.Unix() / int64(totpPeriod.Seconds())
+}
+
+func decodeTOTPSecret(secret string) ([]byte, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return nil, fmt.Errorf("auth: invalid TOTP secret: %w", err)
+	}
+
+	return key, nil
+}
+
+// hotp implements RFC 4226.
+func hotp(key []byte, counter int64) string {
+	message := make([]byte, 8)
+	binary.BigEndian.PutUint64(message, uint64(counter))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(message)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	modulo := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		modulo *= 10
+	}
+
+	return fmt.Sprintf("%0*d", totpDigits, value%modulo)
+}
+
+// recovery codes avoid characters that are easy to mix up
+const recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+// GenerateRecoveryCodes returns n random codes like "x7kq2-m9dpa". They are
+// meant to be stored with HashToken after normalizing them with
+// NormalizeRecoveryCode.
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		random := make([]byte, 10)
+		_, err := rand.Read(random)
+		if err != nil {
+			return nil, err
+		}
+
+		code := make([]byte, 0, 11)
+		for j, b := range random {
+			if j == 5 {
+				code = append(code, '-')
+			}
+			// 256 is not a multiple of the alphabet size, the small bias
+			// does not matter with 10 characters of entropy each
+			code = append(code, recoveryCodeAlphabet[int(b)%len(recoveryCodeAlphabet)])
+		}
+		codes = append(codes, string(code))
+	}
+
+	return codes, nil
+}
+
+// NormalizeRecoveryCode ignores case, spaces and dashes so codes typed by hand
+// still match.
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, code)
+}
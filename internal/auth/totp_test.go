@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// test vectors from RFC 6238 appendix B, truncated to 6 digits
+func TestTOTPCode(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	cases := []struct {
+		unix int64
+		code string
+	}{
+		{unix: 59, code: "287082"},
+		{unix: 1111111109, code: "081804"},
+		{unix: 1234567890, code: "005924"},
+		{unix: 2000000000, code: "279037"},
+	}
+
+	for _, c := range cases {
+		code, err := TOTPCode(secret, time.Unix(c.unix, 0))
+		if err != nil {
+			t.Fatalf("Test TOTPCode failed with err: %v", err)
+		}
+		if code != c.code {
+			t.Errorf("Test TOTPCode(%d) failed: expected %s, got %s", c.unix, c.code, code)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("Test ValidateTOTP::GenerateTOTPSecret failed with err: %v", err)
+	}
+
+	now := time.Unix(1700000000, 0)
+	code, err := TOTPCode(secret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	step, ok, err := ValidateTOTP(secret, code, now.Add(30*time.Second))
+	if err != nil || !ok {
+		t.Errorf("Test ValidateTOTP failed: code of the previous step rejected, err: %v", err)
+	}
+	if step != now.Unix()/30 {
+		t.Errorf("Test ValidateTOTP failed: expected step %d, got %d", now.Unix()/30, step)
+	}
+
+	_, ok, _ = ValidateTOTP(secret, code, now.Add(2*time.Minute))
+	if ok {
+		t.Errorf("Test ValidateTOTP failed: outdated code accepted")
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := TOTPURI("Chirpy", "user@example.com", "JBSWY3DPEHPK3PXP")
+
+	if !strings.HasPrefix(uri, "otpauth://totp/Chirpy:user@example.com?") {
+		t.Errorf("Test TOTPURI failed: unexpected label in %q", uri)
+	}
+	if !strings.Contains(uri, "secret=JBSWY3DPEHPK3PXP") || !strings.Contains(uri, "issuer=Chirpy") {
+		t.Errorf("Test TOTPURI failed: missing parameters in %q", uri)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatalf("Test RecoveryCodes failed with err: %v", err)
+	}
+
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Errorf("Test RecoveryCodes failed: unexpected format %q", code)
+		}
+		if seen[code] {
+			t.Errorf("Test RecoveryCodes failed: duplicate code %q", code)
+		}
+		seen[code] = true
+	}
+
+	if NormalizeRecoveryCode(" X7KQ2-m9dpa") != "x7kq2m9dpa" {
+		t.Errorf("Test RecoveryCodes failed: unexpected normalization %q", NormalizeRecoveryCode(" X7KQ2-m9dpa"))
+	}
+}
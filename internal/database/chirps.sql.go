@@ -7,29 +7,63 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const chirpWasPublished = `-- name: ChirpWasPublished :one
+SELECT EXISTS(
+  SELECT 1 FROM chirps
+  WHERE id = $1 AND publish_at IS NULL
+)
+`
+
+// deleted and hidden chirps count as well, their replies stay visible
+func (q *Queries) ChirpWasPublished(ctx context.Context, id uuid.UUID) (bool, error) {
+	row := q.db.QueryRowContext(ctx, chirpWasPublished, id)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, body, user_id, created_at, updated_at)
+INSERT INTO chirps (id, body, user_id, reply_to_id, quote_of_id, publish_at, created_at, updated_at)
 VALUES (
   gen_random_uuid(),
   $1,
   $2,
-  NOW(),
-  NOW()
+  $3,
+  $4,
+  $5,
+  $6,
+  $6
 )
-RETURNING id, body, created_at, updated_at, user_id
+RETURNING id, body, created_at, updated_at, user_id, deleted_at, hidden_at, reply_to_id, rechirp_of_id, quote_of_id, publish_at
 `
 
 type CreateChirpParams struct {
-	Body   string    `json:"body"`
-	UserID uuid.UUID `json:"user_id"`
+	Body      string        `json:"body"`
+	UserID    uuid.UUID     `json:"user_id"`
+	ReplyToID uuid.NullUUID `json:"reply_to_id"`
+	QuoteOfID uuid.NullUUID `json:"quote_of_id"`
+	PublishAt sql.NullTime  `json:"publish_at"`
+	CreatedAt time.Time     `json:"created_at"`
 }
 
+// created_at comes from the server like publish_at, so scheduled chirps are
+// dated with the same clock as the others
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.Body,
+		arg.UserID,
+		arg.ReplyToID,
+		arg.QuoteOfID,
+		arg.PublishAt,
+		arg.CreatedAt,
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -37,23 +71,90 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.UserID,
+		&i.DeletedAt,
+		&i.HiddenAt,
+		&i.ReplyToID,
+		&i.RechirpOfID,
+		&i.QuoteOfID,
+		&i.PublishAt,
 	)
 	return i, err
 }
 
-const deleteChirpByID = `-- name: DeleteChirpByID :exec
-DELETE from chirps
+const createRechirp = `-- name: CreateRechirp :one
+INSERT INTO chirps (id, body, user_id, rechirp_of_id, created_at, updated_at)
+VALUES (
+  gen_random_uuid(),
+  '',
+  $1,
+  $2,
+  $3,
+  $3
+)
+ON CONFLICT (user_id, rechirp_of_id) WHERE rechirp_of_id IS NOT NULL AND deleted_at IS NULL DO NOTHING
+RETURNING id, body, created_at, updated_at, user_id, deleted_at, hidden_at, reply_to_id, rechirp_of_id, quote_of_id, publish_at
+`
+
+type CreateRechirpParams struct {
+	UserID      uuid.UUID     `json:"user_id"`
+	RechirpOfID uuid.NullUUID `json:"rechirp_of_id"`
+	CreatedAt   time.Time     `json:"created_at"`
+}
+
+func (q *Queries) CreateRechirp(ctx context.Context, arg CreateRechirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, createRechirp, arg.UserID, arg.RechirpOfID, arg.CreatedAt)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.Body,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.DeletedAt,
+		&i.HiddenAt,
+		&i.ReplyToID,
+		&i.RechirpOfID,
+		&i.QuoteOfID,
+		&i.PublishAt,
+	)
+	return i, err
+}
+
+const deleteChirpByID = `-- name: DeleteChirpByID :execrows
+DELETE FROM chirps
 WHERE id = $1
 `
 
-func (q *Queries) DeleteChirpByID(ctx context.Context, id uuid.UUID) error {
-	_, err := q.db.ExecContext(ctx, deleteChirpByID, id)
-	return err
+func (q *Queries) DeleteChirpByID(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteChirpByID, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteRechirp = `-- name: DeleteRechirp :execrows
+DELETE FROM chirps
+WHERE user_id = $1 AND rechirp_of_id = $2 AND deleted_at IS NULL
+`
+
+type DeleteRechirpParams struct {
+	UserID      uuid.UUID     `json:"user_id"`
+	RechirpOfID uuid.NullUUID `json:"rechirp_of_id"`
+}
+
+// plain rechirps are not restorable, so undoing one removes it for good
+func (q *Queries) DeleteRechirp(ctx context.Context, arg DeleteRechirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteRechirp, arg.UserID, arg.RechirpOfID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
 const getChirpByID = `-- name: GetChirpByID :one
-SELECT id, body, created_at, updated_at, user_id FROM chirps
-WHERE id = $1
+SELECT id, body, created_at, updated_at, user_id, deleted_at, hidden_at, reply_to_id, rechirp_of_id, quote_of_id, publish_at FROM chirps
+WHERE id = $1 AND deleted_at IS NULL AND hidden_at IS NULL AND publish_at IS NULL
 `
 
 func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error) {
@@ -65,12 +166,80 @@ func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error)
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.UserID,
+		&i.DeletedAt,
+		&i.HiddenAt,
+		&i.ReplyToID,
+		&i.RechirpOfID,
+		&i.QuoteOfID,
+		&i.PublishAt,
 	)
 	return i, err
 }
 
+const getChirpStats = `-- name: GetChirpStats :many
+SELECT chirps.id AS chirp_id,
+  (SELECT COUNT(*) FROM chirp_likes WHERE chirp_likes.chirp_id = chirps.id) AS like_count,
+  EXISTS(
+    SELECT 1 FROM chirp_likes
+    WHERE chirp_likes.chirp_id = chirps.id AND chirp_likes.user_id = $1::uuid
+  ) AS liked_by_viewer,
+  (
+    SELECT COUNT(*) FROM chirps AS replies
+    WHERE replies.reply_to_id = chirps.id AND replies.deleted_at IS NULL AND replies.hidden_at IS NULL AND replies.publish_at IS NULL
+  ) AS reply_count,
+  users.handle AS author_handle
+FROM chirps
+INNER JOIN users ON users.id = chirps.user_id
+WHERE chirps.id = ANY($2::uuid[])
+`
+
+type GetChirpStatsParams struct {
+	ViewerID uuid.NullUUID `json:"viewer_id"`
+	ChirpIds []uuid.UUID   `json:"chirp_ids"`
+}
+
+type GetChirpStatsRow struct {
+	ChirpID       uuid.UUID `json:"chirp_id"`
+	LikeCount     int64     `json:"like_count"`
+	LikedByViewer bool      `json:"liked_by_viewer"`
+	ReplyCount    int64     `json:"reply_count"`
+	AuthorHandle  string    `json:"author_handle"`
+}
+
+// counts for a whole page of chirps at once, liked_by_viewer is false if
+// viewer_id is NULL
+func (q *Queries) GetChirpStats(ctx context.Context, arg GetChirpStatsParams) ([]GetChirpStatsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpStats, arg.ViewerID, pq.Array(arg.ChirpIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetChirpStatsRow
+	for rows.Next() {
+		var i GetChirpStatsRow
+		if err := rows.Scan(
+			&i.ChirpID,
+			&i.LikeCount,
+			&i.LikedByViewer,
+			&i.ReplyCount,
+			&i.AuthorHandle,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getChirps = `-- name: GetChirps :many
-SELECT id, body, created_at, updated_at, user_id FROM chirps
+SELECT id, body, created_at, updated_at, user_id, deleted_at, hidden_at, reply_to_id, rechirp_of_id, quote_of_id, publish_at FROM chirps
+WHERE deleted_at IS NULL AND hidden_at IS NULL AND publish_at IS NULL
 ORDER BY created_at ASC
 `
 
@@ -89,6 +258,52 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.UserID,
+			&i.DeletedAt,
+			&i.HiddenAt,
+			&i.ReplyToID,
+			&i.RechirpOfID,
+			&i.QuoteOfID,
+			&i.PublishAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsByIDs = `-- name: GetChirpsByIDs :many
+SELECT id, body, created_at, updated_at, user_id, deleted_at, hidden_at, reply_to_id, rechirp_of_id, quote_of_id, publish_at FROM chirps
+WHERE id = ANY($1::uuid[]) AND deleted_at IS NULL AND hidden_at IS NULL AND publish_at IS NULL
+`
+
+func (q *Queries) GetChirpsByIDs(ctx context.Context, ids []uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByIDs, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.Body,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.DeletedAt,
+			&i.HiddenAt,
+			&i.ReplyToID,
+			&i.RechirpOfID,
+			&i.QuoteOfID,
+			&i.PublishAt,
 		); err != nil {
 			return nil, err
 		}
@@ -104,8 +319,8 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 }
 
 const getChirpsByUserID = `-- name: GetChirpsByUserID :many
-SELECT id, body, created_at, updated_at, user_id FROM chirps
-WHERE user_id = $1
+SELECT id, body, created_at, updated_at, user_id, deleted_at, hidden_at, reply_to_id, rechirp_of_id, quote_of_id, publish_at FROM chirps
+WHERE user_id = $1 AND deleted_at IS NULL AND hidden_at IS NULL AND publish_at IS NULL
 ORDER BY created_at ASC
 `
 
@@ -124,6 +339,217 @@ func (q *Queries) GetChirpsByUserID(ctx context.Context, userID uuid.UUID) ([]Ch
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.UserID,
+			&i.DeletedAt,
+			&i.HiddenAt,
+			&i.ReplyToID,
+			&i.RechirpOfID,
+			&i.QuoteOfID,
+			&i.PublishAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsPageAsc = `-- name: GetChirpsPageAsc :many
+SELECT id, body, created_at, updated_at, user_id, deleted_at, hidden_at, reply_to_id, rechirp_of_id, quote_of_id, publish_at FROM chirps
+WHERE deleted_at IS NULL AND hidden_at IS NULL AND publish_at IS NULL
+  AND ($1::uuid IS NULL OR user_id = $1::uuid)
+  -- plain rechirps only show up on the profile of whoever rechirped
+  AND ($1::uuid IS NOT NULL OR rechirp_of_id IS NULL)
+  AND (
+    $2::timestamp IS NULL
+    OR (created_at, id) > ($2::timestamp, $3::uuid)
+  )
+ORDER BY created_at ASC, id ASC
+LIMIT $4
+`
+
+type GetChirpsPageAscParams struct {
+	AuthorID        uuid.NullUUID `json:"author_id"`
+	CursorCreatedAt sql.NullTime  `json:"cursor_created_at"`
+	CursorID        uuid.NullUUID `json:"cursor_id"`
+	PageLimit       int32         `json:"page_limit"`
+}
+
+func (q *Queries) GetChirpsPageAsc(ctx context.Context, arg GetChirpsPageAscParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsPageAsc,
+		arg.AuthorID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.Body,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.DeletedAt,
+			&i.HiddenAt,
+			&i.ReplyToID,
+			&i.RechirpOfID,
+			&i.QuoteOfID,
+			&i.PublishAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsPageDesc = `-- name: GetChirpsPageDesc :many
+SELECT id, body, created_at, updated_at, user_id, deleted_at, hidden_at, reply_to_id, rechirp_of_id, quote_of_id, publish_at FROM chirps
+WHERE deleted_at IS NULL AND hidden_at IS NULL AND publish_at IS NULL
+  AND ($1::uuid IS NULL OR user_id = $1::uuid)
+  -- plain rechirps only show up on the profile of whoever rechirped
+  AND ($1::uuid IS NOT NULL OR rechirp_of_id IS NULL)
+  AND (
+    $2::timestamp IS NULL
+    OR (created_at, id) < ($2::timestamp, $3::uuid)
+  )
+ORDER BY created_at DESC, id DESC
+LIMIT $4
+`
+
+type GetChirpsPageDescParams struct {
+	AuthorID        uuid.NullUUID `json:"author_id"`
+	CursorCreatedAt sql.NullTime  `json:"cursor_created_at"`
+	CursorID        uuid.NullUUID `json:"cursor_id"`
+	PageLimit       int32         `json:"page_limit"`
+}
+
+func (q *Queries) GetChirpsPageDesc(ctx context.Context, arg GetChirpsPageDescParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsPageDesc,
+		arg.AuthorID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.Body,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.DeletedAt,
+			&i.HiddenAt,
+			&i.ReplyToID,
+			&i.RechirpOfID,
+			&i.QuoteOfID,
+			&i.PublishAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getDeletedChirpByID = `-- name: GetDeletedChirpByID :one
+SELECT id, body, created_at, updated_at, user_id, deleted_at, hidden_at, reply_to_id, rechirp_of_id, quote_of_id, publish_at FROM chirps
+WHERE id = $1 AND deleted_at IS NOT NULL
+`
+
+func (q *Queries) GetDeletedChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getDeletedChirpByID, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.Body,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.DeletedAt,
+		&i.HiddenAt,
+		&i.ReplyToID,
+		&i.RechirpOfID,
+		&i.QuoteOfID,
+		&i.PublishAt,
+	)
+	return i, err
+}
+
+const getRepliesPage = `-- name: GetRepliesPage :many
+SELECT id, body, created_at, updated_at, user_id, deleted_at, hidden_at, reply_to_id, rechirp_of_id, quote_of_id, publish_at FROM chirps
+WHERE reply_to_id = $1
+  AND deleted_at IS NULL AND hidden_at IS NULL AND publish_at IS NULL
+  AND (
+    $2::timestamp IS NULL
+    OR (created_at, id) > ($2::timestamp, $3::uuid)
+  )
+ORDER BY created_at ASC, id ASC
+LIMIT $4
+`
+
+type GetRepliesPageParams struct {
+	ReplyToID       uuid.NullUUID `json:"reply_to_id"`
+	CursorCreatedAt sql.NullTime  `json:"cursor_created_at"`
+	CursorID        uuid.NullUUID `json:"cursor_id"`
+	PageLimit       int32         `json:"page_limit"`
+}
+
+func (q *Queries) GetRepliesPage(ctx context.Context, arg GetRepliesPageParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getRepliesPage,
+		arg.ReplyToID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.Body,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.DeletedAt,
+			&i.HiddenAt,
+			&i.ReplyToID,
+			&i.RechirpOfID,
+			&i.QuoteOfID,
+			&i.PublishAt,
 		); err != nil {
 			return nil, err
 		}
@@ -137,3 +563,96 @@ func (q *Queries) GetChirpsByUserID(ctx context.Context, userID uuid.UUID) ([]Ch
 	}
 	return items, nil
 }
+
+const hideChirpByID = `-- name: HideChirpByID :execrows
+UPDATE chirps
+SET hidden_at = $1::timestamp
+WHERE id = $2 AND deleted_at IS NULL
+`
+
+type HideChirpByIDParams struct {
+	HiddenAt time.Time `json:"hidden_at"`
+	ID       uuid.UUID `json:"id"`
+}
+
+func (q *Queries) HideChirpByID(ctx context.Context, arg HideChirpByIDParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, hideChirpByID, arg.HiddenAt, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const purgeDeletedChirps = `-- name: PurgeDeletedChirps :execrows
+DELETE FROM chirps
+WHERE deleted_at < $1::timestamp
+`
+
+func (q *Queries) PurgeDeletedChirps(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeDeletedChirps, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const restoreChirpByID = `-- name: RestoreChirpByID :one
+UPDATE chirps
+SET deleted_at = NULL
+WHERE id = $1 AND deleted_at >= $2::timestamp
+RETURNING id, body, created_at, updated_at, user_id, deleted_at, hidden_at, reply_to_id, rechirp_of_id, quote_of_id, publish_at
+`
+
+type RestoreChirpByIDParams struct {
+	ID           uuid.UUID `json:"id"`
+	DeletedAfter time.Time `json:"deleted_after"`
+}
+
+func (q *Queries) RestoreChirpByID(ctx context.Context, arg RestoreChirpByIDParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, restoreChirpByID, arg.ID, arg.DeletedAfter)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.Body,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.DeletedAt,
+		&i.HiddenAt,
+		&i.ReplyToID,
+		&i.RechirpOfID,
+		&i.QuoteOfID,
+		&i.PublishAt,
+	)
+	return i, err
+}
+
+const softDeleteChirpByID = `-- name: SoftDeleteChirpByID :exec
+UPDATE chirps
+SET deleted_at = $1::timestamp
+WHERE id = $2 AND deleted_at IS NULL
+`
+
+type SoftDeleteChirpByIDParams struct {
+	DeletedAt time.Time `json:"deleted_at"`
+	ID        uuid.UUID `json:"id"`
+}
+
+func (q *Queries) SoftDeleteChirpByID(ctx context.Context, arg SoftDeleteChirpByIDParams) error {
+	_, err := q.db.ExecContext(ctx, softDeleteChirpByID, arg.DeletedAt, arg.ID)
+	return err
+}
+
+const unhideChirpByID = `-- name: UnhideChirpByID :execrows
+UPDATE chirps
+SET hidden_at = NULL
+WHERE id = $1 AND deleted_at IS NULL
+`
+
+func (q *Queries) UnhideChirpByID(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, unhideChirpByID, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
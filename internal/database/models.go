@@ -11,28 +11,198 @@ import (
 	"github.com/google/uuid"
 )
 
+type AccessTokenCutoff struct {
+	UserID        uuid.UUID `json:"user_id"`
+	RevokedBefore time.Time `json:"revoked_before"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
 type Chirp struct {
-	ID        uuid.UUID `json:"id"`
-	Body      string    `json:"body"`
+	ID          uuid.UUID     `json:"id"`
+	Body        string        `json:"body"`
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+	UserID      uuid.UUID     `json:"user_id"`
+	DeletedAt   sql.NullTime  `json:"deleted_at"`
+	HiddenAt    sql.NullTime  `json:"hidden_at"`
+	ReplyToID   uuid.NullUUID `json:"reply_to_id"`
+	RechirpOfID uuid.NullUUID `json:"rechirp_of_id"`
+	QuoteOfID   uuid.NullUUID `json:"quote_of_id"`
+	PublishAt   sql.NullTime  `json:"publish_at"`
+}
+
+type ChirpHashtag struct {
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	HashtagID uuid.UUID `json:"hashtag_id"`
+}
+
+type ChirpLike struct {
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	UserID    uuid.UUID `json:"user_id"`
 	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type ChirpReport struct {
+	ID         uuid.UUID      `json:"id"`
+	ChirpID    uuid.NullUUID  `json:"chirp_id"`
+	ReporterID uuid.NullUUID  `json:"reporter_id"`
+	Reason     string         `json:"reason"`
+	CreatedAt  time.Time      `json:"created_at"`
+	ResolvedAt sql.NullTime   `json:"resolved_at"`
+	ResolvedBy uuid.NullUUID  `json:"resolved_by"`
+	Resolution sql.NullString `json:"resolution"`
+}
+
+type ChirpRevision struct {
+	ID         uuid.UUID `json:"id"`
+	ChirpID    uuid.UUID `json:"chirp_id"`
+	Body       string    `json:"body"`
+	CreatedAt  time.Time `json:"created_at"`
+	ReplacedAt time.Time `json:"replaced_at"`
+}
+
+type EmailVerificationToken struct {
+	TokenHash string    `json:"token_hash"`
 	UserID    uuid.UUID `json:"user_id"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
 }
 
-type RefreshToken struct {
-	Token     string       `json:"token"`
-	CreatedAt time.Time    `json:"created_at"`
-	UpdatedAt time.Time    `json:"updated_at"`
+type Follow struct {
+	FollowerID uuid.UUID `json:"follower_id"`
+	FolloweeID uuid.UUID `json:"followee_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type Hashtag struct {
+	ID        uuid.UUID `json:"id"`
+	Tag       string    `json:"tag"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Medium struct {
+	ID                   uuid.UUID     `json:"id"`
+	UserID               uuid.NullUUID `json:"user_id"`
+	ChirpID              uuid.NullUUID `json:"chirp_id"`
+	AttachedAt           sql.NullTime  `json:"attached_at"`
+	Position             int32         `json:"position"`
+	ContentType          string        `json:"content_type"`
+	StorageKey           string        `json:"storage_key"`
+	ThumbnailContentType string        `json:"thumbnail_content_type"`
+	ThumbnailKey         string        `json:"thumbnail_key"`
+	Width                int32         `json:"width"`
+	Height               int32         `json:"height"`
+	SizeBytes            int64         `json:"size_bytes"`
+	CreatedAt            time.Time     `json:"created_at"`
+}
+
+type Mention struct {
+	ChirpID    uuid.UUID `json:"chirp_id"`
+	UserID     uuid.UUID `json:"user_id"`
+	StartIndex int32     `json:"start_index"`
+	EndIndex   int32     `json:"end_index"`
+}
+
+type ModerationWord struct {
+	Word      string    `json:"word"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type OidcLoginState struct {
+	StateHash    string    `json:"state_hash"`
+	Nonce        string    `json:"nonce"`
+	CodeVerifier string    `json:"code_verifier"`
+	CreatedAt    time.Time `json:"created_at"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+type PasswordResetToken struct {
+	TokenHash string       `json:"token_hash"`
 	UserID    uuid.UUID    `json:"user_id"`
+	CreatedAt time.Time    `json:"created_at"`
 	ExpiresAt time.Time    `json:"expires_at"`
-	RevokedAt sql.NullTime `json:"revoked_at"`
+	UsedAt    sql.NullTime `json:"used_at"`
+}
+
+type PersonalAccessToken struct {
+	ID         uuid.UUID    `json:"id"`
+	UserID     uuid.UUID    `json:"user_id"`
+	Name       string       `json:"name"`
+	TokenHash  string       `json:"token_hash"`
+	Scopes     []string     `json:"scopes"`
+	CreatedAt  time.Time    `json:"created_at"`
+	ExpiresAt  sql.NullTime `json:"expires_at"`
+	LastUsedAt sql.NullTime `json:"last_used_at"`
+}
+
+type RecoveryCode struct {
+	UserID    uuid.UUID    `json:"user_id"`
+	CodeHash  string       `json:"code_hash"`
+	CreatedAt time.Time    `json:"created_at"`
+	UsedAt    sql.NullTime `json:"used_at"`
+}
+
+type RefreshToken struct {
+	CreatedAt  time.Time    `json:"created_at"`
+	UpdatedAt  time.Time    `json:"updated_at"`
+	UserID     uuid.UUID    `json:"user_id"`
+	ExpiresAt  time.Time    `json:"expires_at"`
+	RevokedAt  sql.NullTime `json:"revoked_at"`
+	FamilyID   uuid.UUID    `json:"family_id"`
+	ReplacedAt sql.NullTime `json:"replaced_at"`
+	TokenHash  string       `json:"token_hash"`
+	UserAgent  string       `json:"user_agent"`
+	IpAddress  string       `json:"ip_address"`
+	LastUsedAt time.Time    `json:"last_used_at"`
+}
+
+type RevokedAccessToken struct {
+	Jti       string    `json:"jti"`
+	UserID    uuid.UUID `json:"user_id"`
+	RevokedAt time.Time `json:"revoked_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type TwoFactorChallenge struct {
+	TokenHash string    `json:"token_hash"`
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Attempts  int32     `json:"attempts"`
 }
 
 type User struct {
-	ID             uuid.UUID `json:"id"`
-	Email          string    `json:"email"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
-	HashedPassword string    `json:"hashed_password"`
-	IsPremium      bool      `json:"is_premium"`
+	ID              uuid.UUID    `json:"id"`
+	Email           string       `json:"email"`
+	CreatedAt       time.Time    `json:"created_at"`
+	UpdatedAt       time.Time    `json:"updated_at"`
+	HashedPassword  string       `json:"hashed_password"`
+	IsPremium       bool         `json:"is_premium"`
+	DeletedAt       sql.NullTime `json:"deleted_at"`
+	Role            string       `json:"role"`
+	Handle          string       `json:"handle"`
+	DisplayName     string       `json:"display_name"`
+	Bio             string       `json:"bio"`
+	EmailVerifiedAt sql.NullTime `json:"email_verified_at"`
+}
+
+type UserIdentity struct {
+	ID          uuid.UUID `json:"id"`
+	UserID      uuid.UUID `json:"user_id"`
+	Issuer      string    `json:"issuer"`
+	Subject     string    `json:"subject"`
+	Email       string    `json:"email"`
+	CreatedAt   time.Time `json:"created_at"`
+	LastLoginAt time.Time `json:"last_login_at"`
+}
+
+type UserTotp struct {
+	UserID         uuid.UUID     `json:"user_id"`
+	Secret         string        `json:"secret"`
+	CreatedAt      time.Time     `json:"created_at"`
+	EnabledAt      sql.NullTime  `json:"enabled_at"`
+	LastUsedStep   sql.NullInt64 `json:"last_used_step"`
+	FailedAttempts int32         `json:"failed_attempts"`
+	LockedUntil    sql.NullTime  `json:"locked_until"`
 }
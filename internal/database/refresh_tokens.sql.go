@@ -13,73 +13,244 @@ import (
 )
 
 const createRefreshToken = `-- name: CreateRefreshToken :one
-INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at)
+INSERT INTO refresh_tokens (token_hash, created_at, updated_at, last_used_at, user_id, expires_at, family_id, user_agent, ip_address)
 VALUES (
   $1,
   NOW(),
   NOW(),
+  NOW(),
   $2,
-  $3
+  $3,
+  $4,
+  $5,
+  $6
 )
-RETURNING token, created_at, updated_at, user_id, expires_at, revoked_at
+RETURNING created_at, updated_at, user_id, expires_at, revoked_at, family_id, replaced_at, token_hash, user_agent, ip_address, last_used_at
 `
 
 type CreateRefreshTokenParams struct {
-	Token     string    `json:"token"`
+	TokenHash string    `json:"token_hash"`
 	UserID    uuid.UUID `json:"user_id"`
 	ExpiresAt time.Time `json:"expires_at"`
+	FamilyID  uuid.UUID `json:"family_id"`
+	UserAgent string    `json:"user_agent"`
+	IpAddress string    `json:"ip_address"`
 }
 
 func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshTokenParams) (RefreshToken, error) {
-	row := q.db.QueryRowContext(ctx, createRefreshToken, arg.Token, arg.UserID, arg.ExpiresAt)
+	row := q.db.QueryRowContext(ctx, createRefreshToken,
+		arg.TokenHash,
+		arg.UserID,
+		arg.ExpiresAt,
+		arg.FamilyID,
+		arg.UserAgent,
+		arg.IpAddress,
+	)
 	var i RefreshToken
 	err := row.Scan(
-		&i.Token,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.UserID,
 		&i.ExpiresAt,
 		&i.RevokedAt,
+		&i.FamilyID,
+		&i.ReplacedAt,
+		&i.TokenHash,
+		&i.UserAgent,
+		&i.IpAddress,
+		&i.LastUsedAt,
 	)
 	return i, err
 }
 
 const getRefreshToken = `-- name: GetRefreshToken :one
-SELECT token, created_at, updated_at, user_id, expires_at, revoked_at FROM refresh_tokens
-WHERE token = $1
+SELECT created_at, updated_at, user_id, expires_at, revoked_at, family_id, replaced_at, token_hash, user_agent, ip_address, last_used_at FROM refresh_tokens
+WHERE token_hash = $1
 `
 
-func (q *Queries) GetRefreshToken(ctx context.Context, token string) (RefreshToken, error) {
-	row := q.db.QueryRowContext(ctx, getRefreshToken, token)
+func (q *Queries) GetRefreshToken(ctx context.Context, tokenHash string) (RefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, getRefreshToken, tokenHash)
 	var i RefreshToken
 	err := row.Scan(
-		&i.Token,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.UserID,
 		&i.ExpiresAt,
 		&i.RevokedAt,
+		&i.FamilyID,
+		&i.ReplacedAt,
+		&i.TokenHash,
+		&i.UserAgent,
+		&i.IpAddress,
+		&i.LastUsedAt,
 	)
 	return i, err
 }
 
-const getUserFromRefreshToken = `-- name: GetUserFromRefreshToken :one
-SELECT u.id, u.email, u.created_at, u.updated_at, u.hashed_password, u.is_premium
-FROM users U
-INNER JOIN refresh_tokens R ON R.user_id = U.id
-WHERE token = $1
+const getUserSessions = `-- name: GetUserSessions :many
+SELECT
+  R.family_id,
+  R.user_agent,
+  R.ip_address,
+  R.last_used_at,
+  R.expires_at,
+  (
+    SELECT MIN(F.created_at) FROM refresh_tokens F
+    WHERE F.family_id = R.family_id
+  )::timestamp AS started_at
+FROM refresh_tokens R
+WHERE R.user_id = $1 AND R.revoked_at IS NULL AND R.expires_at > $2::timestamp
+ORDER BY R.last_used_at DESC
+`
+
+type GetUserSessionsParams struct {
+	UserID uuid.UUID `json:"user_id"`
+	Now    time.Time `json:"now"`
+}
+
+type GetUserSessionsRow struct {
+	FamilyID   uuid.UUID `json:"family_id"`
+	UserAgent  string    `json:"user_agent"`
+	IpAddress  string    `json:"ip_address"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	StartedAt  time.Time `json:"started_at"`
+}
+
+// every token family with a token that can still be used is a session, the
+// session started when the first token of the family was created
+func (q *Queries) GetUserSessions(ctx context.Context, arg GetUserSessionsParams) ([]GetUserSessionsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUserSessions, arg.UserID, arg.Now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUserSessionsRow
+	for rows.Next() {
+		var i GetUserSessionsRow
+		if err := rows.Scan(
+			&i.FamilyID,
+			&i.UserAgent,
+			&i.IpAddress,
+			&i.LastUsedAt,
+			&i.ExpiresAt,
+			&i.StartedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const revokeRefreshTokenFamily = `-- name: RevokeRefreshTokenFamily :exec
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE family_id = $1 AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeRefreshTokenFamily(ctx context.Context, familyID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, revokeRefreshTokenFamily, familyID)
+	return err
+}
+
+const revokeUserSession = `-- name: RevokeUserSession :execrows
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE family_id = $1 AND user_id = $2 AND revoked_at IS NULL
+`
+
+type RevokeUserSessionParams struct {
+	FamilyID uuid.UUID `json:"family_id"`
+	UserID   uuid.UUID `json:"user_id"`
+}
+
+func (q *Queries) RevokeUserSession(ctx context.Context, arg RevokeUserSessionParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeUserSession, arg.FamilyID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const revokeUserSessions = `-- name: RevokeUserSessions :execrows
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL
 `
 
-func (q *Queries) GetUserFromRefreshToken(ctx context.Context, token string) (User, error) {
-	row := q.db.QueryRowContext(ctx, getUserFromRefreshToken, token)
-	var i User
+func (q *Queries) RevokeUserSessions(ctx context.Context, userID uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeUserSessions, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const rotateRefreshToken = `-- name: RotateRefreshToken :one
+WITH rotated AS (
+  UPDATE refresh_tokens
+  SET replaced_at = NOW(), revoked_at = NOW(), updated_at = NOW()
+  WHERE refresh_tokens.token_hash = $1
+    AND refresh_tokens.replaced_at IS NULL
+    AND refresh_tokens.revoked_at IS NULL
+    AND refresh_tokens.expires_at > $2::timestamp
+  RETURNING refresh_tokens.user_id, refresh_tokens.family_id
+)
+INSERT INTO refresh_tokens (token_hash, created_at, updated_at, last_used_at, user_id, expires_at, family_id, user_agent, ip_address)
+SELECT
+  $3::text,
+  NOW(),
+  NOW(),
+  NOW(),
+  rotated.user_id,
+  $4::timestamp,
+  rotated.family_id,
+  $5::text,
+  $6::text
+FROM rotated
+RETURNING created_at, updated_at, user_id, expires_at, revoked_at, family_id, replaced_at, token_hash, user_agent, ip_address, last_used_at
+`
+
+type RotateRefreshTokenParams struct {
+	OldTokenHash string    `json:"old_token_hash"`
+	Now          time.Time `json:"now"`
+	NewTokenHash string    `json:"new_token_hash"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	UserAgent    string    `json:"user_agent"`
+	IpAddress    string    `json:"ip_address"`
+}
+
+// replaces a valid token with a new one of the same family, returns no rows if
+// the token has already been replaced, revoked or is expired
+func (q *Queries) RotateRefreshToken(ctx context.Context, arg RotateRefreshTokenParams) (RefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, rotateRefreshToken,
+		arg.OldTokenHash,
+		arg.Now,
+		arg.NewTokenHash,
+		arg.ExpiresAt,
+		arg.UserAgent,
+		arg.IpAddress,
+	)
+	var i RefreshToken
 	err := row.Scan(
-		&i.ID,
-		&i.Email,
 		&i.CreatedAt,
 		&i.UpdatedAt,
-		&i.HashedPassword,
-		&i.IsPremium,
+		&i.UserID,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.FamilyID,
+		&i.ReplacedAt,
+		&i.TokenHash,
+		&i.UserAgent,
+		&i.IpAddress,
+		&i.LastUsedAt,
 	)
 	return i, err
 }
@@ -87,10 +258,15 @@ func (q *Queries) GetUserFromRefreshToken(ctx context.Context, token string) (Us
 const setRefreshTokenRevokedAt = `-- name: SetRefreshTokenRevokedAt :exec
 UPDATE refresh_tokens
 SET revoked_at = NOW(), updated_at = NOW()
-WHERE token = $1
+WHERE family_id = (
+  SELECT R.family_id FROM refresh_tokens R
+  WHERE R.token_hash = $1
+) AND revoked_at IS NULL
 `
 
-func (q *Queries) SetRefreshTokenRevokedAt(ctx context.Context, token string) error {
-	_, err := q.db.ExecContext(ctx, setRefreshTokenRevokedAt, token)
+// revokes the whole family of the token, tokens it has been rotated into
+// included
+func (q *Queries) SetRefreshTokenRevokedAt(ctx context.Context, tokenHash string) error {
+	_, err := q.db.ExecContext(ctx, setRefreshTokenRevokedAt, tokenHash)
 	return err
 }
@@ -7,29 +7,67 @@ package database
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 const createUser = `-- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, handle, display_name, bio)
 VALUES (
   gen_random_uuid(),
   NOW(),
   NOW(),
   $1,
-  $2
+  $2,
+  $3,
+  $4,
+  $5
 )
-RETURNING id, email, created_at, updated_at, hashed_password, is_premium
+RETURNING id, email, created_at, updated_at, hashed_password, is_premium, deleted_at, role, handle, display_name, bio, email_verified_at
 `
 
 type CreateUserParams struct {
 	Email          string `json:"email"`
 	HashedPassword string `json:"hashed_password"`
+	Handle         string `json:"handle"`
+	DisplayName    string `json:"display_name"`
+	Bio            string `json:"bio"`
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, createUser, arg.Email, arg.HashedPassword)
+	row := q.db.QueryRowContext(ctx, createUser,
+		arg.Email,
+		arg.HashedPassword,
+		arg.Handle,
+		arg.DisplayName,
+		arg.Bio,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.HashedPassword,
+		&i.IsPremium,
+		&i.DeletedAt,
+		&i.Role,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.EmailVerifiedAt,
+	)
+	return i, err
+}
+
+const getDeletedUserByEmail = `-- name: GetDeletedUserByEmail :one
+SELECT id, email, created_at, updated_at, hashed_password, is_premium, deleted_at, role, handle, display_name, bio, email_verified_at FROM users
+WHERE email = $1 AND deleted_at IS NOT NULL
+`
+
+func (q *Queries) GetDeletedUserByEmail(ctx context.Context, email string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getDeletedUserByEmail, email)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -38,13 +76,19 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.UpdatedAt,
 		&i.HashedPassword,
 		&i.IsPremium,
+		&i.DeletedAt,
+		&i.Role,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.EmailVerifiedAt,
 	)
 	return i, err
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, email, created_at, updated_at, hashed_password, is_premium FROM users
-WHERE email = $1
+SELECT id, email, created_at, updated_at, hashed_password, is_premium, deleted_at, role, handle, display_name, bio, email_verified_at FROM users
+WHERE email = $1 AND deleted_at IS NULL
 `
 
 func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
@@ -57,13 +101,19 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.UpdatedAt,
 		&i.HashedPassword,
 		&i.IsPremium,
+		&i.DeletedAt,
+		&i.Role,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.EmailVerifiedAt,
 	)
 	return i, err
 }
 
 const getUserById = `-- name: GetUserById :one
-SELECT id, email, created_at, updated_at, hashed_password, is_premium FROM users
-WHERE id = $1
+SELECT id, email, created_at, updated_at, hashed_password, is_premium, deleted_at, role, handle, display_name, bio, email_verified_at FROM users
+WHERE id = $1 AND deleted_at IS NULL
 `
 
 func (q *Queries) GetUserById(ctx context.Context, id uuid.UUID) (User, error) {
@@ -76,10 +126,171 @@ func (q *Queries) GetUserById(ctx context.Context, id uuid.UUID) (User, error) {
 		&i.UpdatedAt,
 		&i.HashedPassword,
 		&i.IsPremium,
+		&i.DeletedAt,
+		&i.Role,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.EmailVerifiedAt,
+	)
+	return i, err
+}
+
+const getUserProfileByHandle = `-- name: GetUserProfileByHandle :one
+SELECT users.id, users.handle, users.display_name, users.bio, users.created_at,
+  (
+    SELECT COUNT(*) FROM chirps
+    WHERE chirps.user_id = users.id AND chirps.rechirp_of_id IS NULL
+      AND chirps.deleted_at IS NULL AND chirps.hidden_at IS NULL AND chirps.publish_at IS NULL
+  ) AS chirp_count
+FROM users
+WHERE users.handle = $1 AND users.deleted_at IS NULL
+`
+
+type GetUserProfileByHandleRow struct {
+	ID          uuid.UUID `json:"id"`
+	Handle      string    `json:"handle"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	CreatedAt   time.Time `json:"created_at"`
+	ChirpCount  int64     `json:"chirp_count"`
+}
+
+func (q *Queries) GetUserProfileByHandle(ctx context.Context, handle string) (GetUserProfileByHandleRow, error) {
+	row := q.db.QueryRowContext(ctx, getUserProfileByHandle, handle)
+	var i GetUserProfileByHandleRow
+	err := row.Scan(
+		&i.ID,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.CreatedAt,
+		&i.ChirpCount,
 	)
 	return i, err
 }
 
+const handleExists = `-- name: HandleExists :one
+SELECT EXISTS(
+  SELECT 1 FROM users
+  WHERE handle = $1
+)
+`
+
+// handles of deleted users stay taken so they can still be restored
+func (q *Queries) HandleExists(ctx context.Context, handle string) (bool, error) {
+	row := q.db.QueryRowContext(ctx, handleExists, handle)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const lockAdmins = `-- name: LockAdmins :many
+SELECT id FROM users
+WHERE role = 'admin' AND deleted_at IS NULL
+ORDER BY id
+FOR UPDATE
+`
+
+// role changes wait for each other, so two admins cannot demote each other
+// at once and leave no admin behind
+func (q *Queries) LockAdmins(ctx context.Context) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, lockAdmins)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const purgeDeletedUsers = `-- name: PurgeDeletedUsers :execrows
+DELETE FROM users
+WHERE deleted_at < $1::timestamp
+`
+
+func (q *Queries) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeDeletedUsers, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const restoreUserByID = `-- name: RestoreUserByID :one
+WITH deleted_user AS (
+  SELECT users.id, users.deleted_at FROM users
+  WHERE users.id = $1 AND users.deleted_at >= $2::timestamp
+), restored_chirps AS (
+  UPDATE chirps
+  SET deleted_at = NULL
+  FROM deleted_user
+  WHERE chirps.user_id = deleted_user.id AND chirps.deleted_at = deleted_user.deleted_at
+)
+UPDATE users
+SET deleted_at = NULL, updated_at = NOW()
+FROM deleted_user
+WHERE users.id = deleted_user.id
+RETURNING users.id, users.email, users.created_at, users.updated_at, users.hashed_password, users.is_premium, users.deleted_at, users.role, users.handle, users.display_name, users.bio, users.email_verified_at
+`
+
+type RestoreUserByIDParams struct {
+	ID           uuid.UUID `json:"id"`
+	DeletedAfter time.Time `json:"deleted_after"`
+}
+
+func (q *Queries) RestoreUserByID(ctx context.Context, arg RestoreUserByIDParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, restoreUserByID, arg.ID, arg.DeletedAfter)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.HashedPassword,
+		&i.IsPremium,
+		&i.DeletedAt,
+		&i.Role,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.EmailVerifiedAt,
+	)
+	return i, err
+}
+
+const setUserPasswordHash = `-- name: SetUserPasswordHash :exec
+UPDATE users
+SET hashed_password = $1
+WHERE id = $2 AND hashed_password = $3
+`
+
+type SetUserPasswordHashParams struct {
+	HashedPassword string    `json:"hashed_password"`
+	ID             uuid.UUID `json:"id"`
+	OldHash        string    `json:"old_hash"`
+}
+
+// only replaces the hash that was verified, in case the password was changed
+// in the meantime
+func (q *Queries) SetUserPasswordHash(ctx context.Context, arg SetUserPasswordHashParams) error {
+	_, err := q.db.ExecContext(ctx, setUserPasswordHash, arg.HashedPassword, arg.ID, arg.OldHash)
+	return err
+}
+
 const setUserPremium = `-- name: SetUserPremium :exec
 UPDATE users
 SET is_premium = true
@@ -91,11 +302,69 @@ func (q *Queries) SetUserPremium(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
+const setUserRole = `-- name: SetUserRole :one
+UPDATE users
+SET role = $2, updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING id, email, created_at, updated_at, hashed_password, is_premium, deleted_at, role, handle, display_name, bio, email_verified_at
+`
+
+type SetUserRoleParams struct {
+	ID   uuid.UUID `json:"id"`
+	Role string    `json:"role"`
+}
+
+func (q *Queries) SetUserRole(ctx context.Context, arg SetUserRoleParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserRole, arg.ID, arg.Role)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.HashedPassword,
+		&i.IsPremium,
+		&i.DeletedAt,
+		&i.Role,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.EmailVerifiedAt,
+	)
+	return i, err
+}
+
+const softDeleteUserByID = `-- name: SoftDeleteUserByID :exec
+WITH deleted_user AS (
+  UPDATE users
+  SET deleted_at = $1::timestamp, updated_at = NOW()
+  WHERE users.id = $2 AND users.deleted_at IS NULL
+  RETURNING users.id, users.deleted_at
+)
+UPDATE chirps
+SET deleted_at = deleted_user.deleted_at
+FROM deleted_user
+WHERE chirps.user_id = deleted_user.id AND chirps.deleted_at IS NULL
+`
+
+type SoftDeleteUserByIDParams struct {
+	DeletedAt time.Time `json:"deleted_at"`
+	ID        uuid.UUID `json:"id"`
+}
+
+// the users chirps are tombstoned with the same timestamp so restoring the
+// user brings back exactly the chirps deleted along with them
+func (q *Queries) SoftDeleteUserByID(ctx context.Context, arg SoftDeleteUserByIDParams) error {
+	_, err := q.db.ExecContext(ctx, softDeleteUserByID, arg.DeletedAt, arg.ID)
+	return err
+}
+
 const updateUserCredentialsById = `-- name: UpdateUserCredentialsById :one
 UPDATE users
-SET email = $2, hashed_password = $3, updated_at = NOW()
+SET email = $2, hashed_password = $3, updated_at = NOW(),
+  email_verified_at = CASE WHEN users.email = $2 THEN users.email_verified_at END
 WHERE id = $1
-RETURNING id, email, created_at, updated_at, hashed_password, is_premium
+RETURNING id, email, created_at, updated_at, hashed_password, is_premium, deleted_at, role, handle, display_name, bio, email_verified_at
 `
 
 type UpdateUserCredentialsByIdParams struct {
@@ -104,6 +373,7 @@ type UpdateUserCredentialsByIdParams struct {
 	HashedPassword string    `json:"hashed_password"`
 }
 
+// changing the email address makes it unverified again
 func (q *Queries) UpdateUserCredentialsById(ctx context.Context, arg UpdateUserCredentialsByIdParams) (User, error) {
 	row := q.db.QueryRowContext(ctx, updateUserCredentialsById, arg.ID, arg.Email, arg.HashedPassword)
 	var i User
@@ -114,6 +384,51 @@ func (q *Queries) UpdateUserCredentialsById(ctx context.Context, arg UpdateUserC
 		&i.UpdatedAt,
 		&i.HashedPassword,
 		&i.IsPremium,
+		&i.DeletedAt,
+		&i.Role,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.EmailVerifiedAt,
+	)
+	return i, err
+}
+
+const updateUserProfile = `-- name: UpdateUserProfile :one
+UPDATE users
+SET handle = $2, display_name = $3, bio = $4, updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING id, email, created_at, updated_at, hashed_password, is_premium, deleted_at, role, handle, display_name, bio, email_verified_at
+`
+
+type UpdateUserProfileParams struct {
+	ID          uuid.UUID `json:"id"`
+	Handle      string    `json:"handle"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+}
+
+func (q *Queries) UpdateUserProfile(ctx context.Context, arg UpdateUserProfileParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserProfile,
+		arg.ID,
+		arg.Handle,
+		arg.DisplayName,
+		arg.Bio,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.HashedPassword,
+		&i.IsPremium,
+		&i.DeletedAt,
+		&i.Role,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.EmailVerifiedAt,
 	)
 	return i, err
 }
@@ -0,0 +1,125 @@
+package entities
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Hashtag is a `#tag` token found in a chirp body. Tag is lower cased and
+// does not include the `#`.
+type Hashtag struct {
+	Tag   string
+	Start int
+	End   int
+}
+
+// Mention is an `@name` token found in a chirp body, Name is either an email
+// address or a handle and does not include the leading `@`.
+type Mention struct {
+	Name  string
+	Start int
+	End   int
+}
+
+// Entities holds the tokens of a chirp body in the order they appear. Start
+// and End are offsets in characters (not bytes) into the body, End is
+// exclusive.
+type Entities struct {
+	Hashtags []Hashtag
+	Mentions []Mention
+}
+
+const maxTagLength = 100
+
+var (
+	hashtagPattern = regexp.MustCompile(`#([\p{L}\p{N}_]+)`)
+	mentionPattern = regexp.MustCompile(`@([A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}|[A-Za-z0-9_]+)`)
+	tagPattern     = regexp.MustCompile(`^[\p{L}\p{N}_]+$`)
+)
+
+// Extract finds all hashtags and mentions in body. Tokens have to start at
+// the beginning of the body or after a character that can not be part of a
+// word, so `foo#bar` and `mail@example.com` are not picked up.
+func Extract(body string) Entities {
+	return Entities{
+		Hashtags: extractHashtags(body),
+		Mentions: extractMentions(body),
+	}
+}
+
+// Tags returns the distinct tags in the order they first appear.
+func (e Entities) Tags() []string {
+	seen := map[string]struct{}{}
+	tags := []string{}
+
+	for _, hashtag := range e.Hashtags {
+		if _, exists := seen[hashtag.Tag]; exists {
+			continue
+		}
+		seen[hashtag.Tag] = struct{}{}
+		tags = append(tags, hashtag.Tag)
+	}
+
+	return tags
+}
+
+// NormalizeTag turns user input like `#Go` into the form hashtags are stored
+// in, ok is false if tag can not be a hashtag.
+func NormalizeTag(tag string) (string, bool) {
+	tag = strings.ToLower(strings.TrimPrefix(tag, "#"))
+	if len(tag) > maxTagLength || !tagPattern.MatchString(tag) {
+		return "", false
+	}
+
+	return tag, true
+}
+
+func extractHashtags(body string) []Hashtag {
+	hashtags := []Hashtag{}
+
+	for _, match := range hashtagPattern.FindAllStringSubmatchIndex(body, -1) {
+		if !startsToken(body, match[0]) || len(body[match[2]:match[3]]) > maxTagLength {
+			continue
+		}
+
+		hashtags = append(hashtags, Hashtag{
+			Tag:   strings.ToLower(body[match[2]:match[3]]),
+			Start: utf8.RuneCountInString(body[:match[0]]),
+			End:   utf8.RuneCountInString(body[:match[1]]),
+		})
+	}
+
+	return hashtags
+}
+
+func extractMentions(body string) []Mention {
+	mentions := []Mention{}
+
+	for _, match := range mentionPattern.FindAllStringSubmatchIndex(body, -1) {
+		if !startsToken(body, match[0]) {
+			continue
+		}
+
+		mentions = append(mentions, Mention{
+			Name:  strings.ToLower(body[match[2]:match[3]]),
+			Start: utf8.RuneCountInString(body[:match[0]]),
+			End:   utf8.RuneCountInString(body[:match[1]]),
+		})
+	}
+
+	return mentions
+}
+
+// startsToken reports whether a token starting at byte offset idx is not
+// glued to the end of another word.
+func startsToken(body string, idx int) bool {
+	if idx == 0 {
+		return true
+	}
+
+	previous, _ := utf8.DecodeLastRuneInString(body[:idx])
+	return !(previous == '_' || previous == '@' || previous == '#' ||
+		unicode.IsLetter(previous) || unicode.IsDigit(previous))
+}
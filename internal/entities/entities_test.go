@@ -0,0 +1,80 @@
+package entities
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractHashtags(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected []Hashtag
+	}{
+		{input: "no tags here", expected: []Hashtag{}},
+		{input: "#Go is fun", expected: []Hashtag{{Tag: "go", Start: 0, End: 3}}},
+		{input: "learning #go and #SQL!", expected: []Hashtag{
+			{Tag: "go", Start: 9, End: 12},
+			{Tag: "sql", Start: 17, End: 21},
+		}},
+		{input: "issue#42 is not a tag", expected: []Hashtag{}},
+		{input: "ünïcode #café", expected: []Hashtag{{Tag: "café", Start: 8, End: 13}}},
+		{input: "## nothing", expected: []Hashtag{}},
+	}
+
+	for _, c := range cases {
+		actual := Extract(c.input).Hashtags
+		if !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("Extract(%q).Hashtags = %v, expected %v", c.input, actual, c.expected)
+		}
+	}
+}
+
+func TestExtractMentions(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected []Mention
+	}{
+		{input: "hi @alice", expected: []Mention{{Name: "alice", Start: 3, End: 9}}},
+		{input: "@Bob@Example.com.", expected: []Mention{{Name: "bob@example.com", Start: 0, End: 16}}},
+		{input: "mail me at bob@example.com", expected: []Mention{}},
+		{input: "@a_b, @c", expected: []Mention{
+			{Name: "a_b", Start: 0, End: 4},
+			{Name: "c", Start: 6, End: 8},
+		}},
+	}
+
+	for _, c := range cases {
+		actual := Extract(c.input).Mentions
+		if !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("Extract(%q).Mentions = %v, expected %v", c.input, actual, c.expected)
+		}
+	}
+}
+
+func TestTags(t *testing.T) {
+	actual := Extract("#go #Go #sql #go").Tags()
+	expected := []string{"go", "sql"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Tags() = %v, expected %v", actual, expected)
+	}
+}
+
+func TestNormalizeTag(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected string
+		ok       bool
+	}{
+		{input: "Go", expected: "go", ok: true},
+		{input: "#SQL", expected: "sql", ok: true},
+		{input: "two words", ok: false},
+		{input: "", ok: false},
+	}
+
+	for _, c := range cases {
+		actual, ok := NormalizeTag(c.input)
+		if actual != c.expected || ok != c.ok {
+			t.Errorf("NormalizeTag(%q) = (%q, %v), expected (%q, %v)", c.input, actual, ok, c.expected, c.ok)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends emails to users.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// format renders msg as a plain text email.
+func format(from string, msg Message, date time.Time) []byte {
+	headers := []string{
+		"From: " + from,
+		"To: " + msg.To,
+		"Subject: " + msg.Subject,
+		"Date: " + date.Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=utf-8",
+	}
+
+	body := strings.ReplaceAll(msg.Body, "\r\n", "\n")
+	body = strings.ReplaceAll(body, "\n", "\r\n")
+
+	return []byte(strings.Join(headers, "\r\n") + "\r\n\r\n" + body + "\r\n")
+}
+
+// validHeader rejects line breaks so user input can not add headers.
+func validHeader(values ...string) error {
+	for _, value := range values {
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("mail: header value %q contains a line break", value)
+		}
+	}
+
+	return nil
+}
+
+// sendTimeout limits how long sending a single email through SMTP can take.
+const sendTimeout = 30 * time.Second
+
+// SMTPMailer sends emails through an SMTP server, using STARTTLS if the
+// server supports it.
+type SMTPMailer struct {
+	Addr string
+	From string
+	// Auth is optional, e.g. for a local relay
+	Auth smtp.Auth
+}
+
+// NewSMTPMailer uses PLAIN authentication if username is not empty.
+func NewSMTPMailer(addr, from, username, password string) (*SMTPMailer, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("mail: invalid SMTP address %q: %w", addr, err)
+	}
+
+	mailer := &SMTPMailer{Addr: addr, From: from}
+	if username != "" {
+		mailer.Auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return mailer, nil
+}
+
+// Send gives up once ctx is done or after sendTimeout, whichever comes first.
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	err := validHeader(msg.To, msg.Subject)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", m.Addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// closing the connection interrupts whatever the client is waiting for
+	stop := context.AfterFunc(ctx, func() {
+		conn.Close()
+	})
+	defer stop()
+
+	err = m.send(conn, msg)
+	if ctx.Err() != nil {
+		return fmt.Errorf("mail: sending to %s: %w", m.Addr, ctx.Err())
+	}
+
+	return err
+}
+
+// send does what smtp.SendMail does on an existing connection.
+func (m *SMTPMailer) send(conn net.Conn, msg Message) error {
+	host, _, err := net.SplitHostPort(m.Addr)
+	if err != nil {
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		err = client.StartTLS(&tls.Config{ServerName: host})
+		if err != nil {
+			return err
+		}
+	}
+
+	if m.Auth != nil {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return errors.New("mail: server does not support AUTH")
+		}
+		err = client.Auth(m.Auth)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = client.Mail(m.From)
+	if err != nil {
+		return err
+	}
+	err = client.Rcpt(msg.To)
+	if err != nil {
+		return err
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(format(m.From, msg, time.Now()))
+	if err != nil {
+		return err
+	}
+	err = writer.Close()
+	if err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+// WriterMailer writes emails to a writer instead of sending them, which is
+// enough to click through flows during local development.
+type WriterMailer struct {
+	From string
+
+	mu     sync.Mutex
+	writer io.Writer
+}
+
+func NewWriterMailer(w io.Writer, from string) *WriterMailer {
+	return &WriterMailer{From: from, writer: w}
+}
+
+// NewFileMailer appends all emails to the file at path.
+func NewFileMailer(path, from string) (*WriterMailer, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewWriterMailer(file, from), nil
+}
+
+func (m *WriterMailer) Send(ctx context.Context, msg Message) error {
+	err := validHeader(msg.To, msg.Subject)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err = m.writer.Write(append(format(m.From, msg, time.Now()), "\r\n"...))
+	return err
+}
@@ -0,0 +1,88 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormat(t *testing.T) {
+	date := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	msg := Message{To: "user@example.com", Subject: "Hello", Body: "line one\nline two"}
+
+	actual := string(format("chirpy@example.com", msg, date))
+	expected := "From: chirpy@example.com\r\n" +
+		"To: user@example.com\r\n" +
+		"Subject: Hello\r\n" +
+		"Date: Tue, 02 Jan 2024 03:04:05 +0000\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" +
+		"line one\r\nline two\r\n"
+
+	if actual != expected {
+		t.Errorf("Test format failed: expected:\n%q\ngot:\n%q", expected, actual)
+	}
+}
+
+func TestWriterMailer(t *testing.T) {
+	out := bytes.Buffer{}
+	mailer := NewWriterMailer(&out, "chirpy@example.com")
+
+	err := mailer.Send(context.Background(), Message{To: "user@example.com", Subject: "Verify", Body: "token"})
+	if err != nil {
+		t.Fatalf("Test WriterMailer failed with err: %v", err)
+	}
+
+	if !strings.Contains(out.String(), "To: user@example.com") || !strings.Contains(out.String(), "token") {
+		t.Errorf("Test WriterMailer failed: unexpected output %q", out.String())
+	}
+}
+
+func TestHeaderInjection(t *testing.T) {
+	mailer := NewWriterMailer(&bytes.Buffer{}, "chirpy@example.com")
+
+	err := mailer.Send(context.Background(), Message{To: "user@example.com\r\nBcc: other@example.com", Subject: "Hi"})
+	if err == nil {
+		t.Error("Test HeaderInjection failed: expected an error")
+	}
+}
+
+func TestSMTPMailerTimeout(t *testing.T) {
+	// accepts connections but never greets the client
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	mailer, err := NewSMTPMailer(listener.Addr().String(), "chirpy@example.com", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err = mailer.Send(ctx, Message{To: "user@example.com", Subject: "Hello", Body: "Hi"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Test SMTPMailer timeout failed: expected a deadline error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Test SMTPMailer timeout failed: Send took %v", elapsed)
+	}
+}
@@ -0,0 +1,396 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+const (
+	// MaxDimension limits width and height so small files can not decode to
+	// huge images.
+	MaxDimension = 8192
+	// MaxGIFFrames and MaxGIFPixels limit animations, every frame is decoded
+	// into its own image.
+	MaxGIFFrames = 500
+	MaxGIFPixels = 128 << 20
+	// ThumbnailSize is the longest side of a thumbnail in pixels.
+	ThumbnailSize = 320
+	jpegQuality   = 90
+)
+
+var ErrUnsupportedType = errors.New("media: only jpeg, png and gif images are supported")
+
+// Image is an upload that has been decoded and encoded again, which drops all
+// metadata like EXIF. JPEG orientation is applied to the pixels before that
+// so photos keep showing up the right way.
+type Image struct {
+	ContentType          string
+	Extension            string
+	Data                 []byte
+	Width                int
+	Height               int
+	Thumbnail            []byte
+	ThumbnailContentType string
+	ThumbnailExtension   string
+}
+
+// ProcessImage sniffs the type of data, strips its metadata and renders a
+// thumbnail.
+func ProcessImage(data []byte) (Image, error) {
+	contentType := http.DetectContentType(data)
+	switch contentType {
+	case "image/jpeg", "image/png", "image/gif":
+	default:
+		return Image{}, ErrUnsupportedType
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, fmt.Errorf("media: decoding image: %w", err)
+	}
+	if config.Width > MaxDimension || config.Height > MaxDimension {
+		return Image{}, fmt.Errorf("media: images can be at most %dx%d pixels", MaxDimension, MaxDimension)
+	}
+
+	result := Image{ContentType: contentType}
+	var frame image.Image
+	encoded := bytes.Buffer{}
+
+	switch contentType {
+	case "image/jpeg":
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return Image{}, fmt.Errorf("media: decoding image: %w", err)
+		}
+		frame = applyOrientation(img, jpegOrientation(data))
+		err = jpeg.Encode(&encoded, frame, &jpeg.Options{Quality: jpegQuality})
+		if err != nil {
+			return Image{}, err
+		}
+		result.Extension = ".jpg"
+
+	case "image/png":
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return Image{}, fmt.Errorf("media: decoding image: %w", err)
+		}
+		frame = img
+		err = png.Encode(&encoded, frame)
+		if err != nil {
+			return Image{}, err
+		}
+		result.Extension = ".png"
+
+	case "image/gif":
+		// DecodeAll allocates every frame, so they are counted first
+		frames, pixels, err := gifFrames(data)
+		if err != nil {
+			return Image{}, fmt.Errorf("media: decoding image: %w", err)
+		}
+		if frames > MaxGIFFrames {
+			return Image{}, fmt.Errorf("media: animations can have at most %d frames", MaxGIFFrames)
+		}
+		if pixels > MaxGIFPixels {
+			return Image{}, fmt.Errorf("media: animations can have at most %d pixels over all frames", MaxGIFPixels)
+		}
+
+		// all frames are kept so animations keep working
+		animation, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return Image{}, fmt.Errorf("media: decoding image: %w", err)
+		}
+		frame = animation.Image[0]
+		err = gif.EncodeAll(&encoded, animation)
+		if err != nil {
+			return Image{}, err
+		}
+		result.Extension = ".gif"
+	}
+
+	result.Data = encoded.Bytes()
+	result.Width = frame.Bounds().Dx()
+	result.Height = frame.Bounds().Dy()
+
+	thumbnail := bytes.Buffer{}
+	scaled := scaleToFit(frame, ThumbnailSize)
+	if contentType == "image/jpeg" {
+		err = jpeg.Encode(&thumbnail, scaled, &jpeg.Options{Quality: jpegQuality})
+		result.ThumbnailContentType, result.ThumbnailExtension = "image/jpeg", ".jpg"
+	} else {
+		// png keeps the transparency of png and gif images
+		err = png.Encode(&thumbnail, scaled)
+		result.ThumbnailContentType, result.ThumbnailExtension = "image/png", ".png"
+	}
+	if err != nil {
+		return Image{}, err
+	}
+	result.Thumbnail = thumbnail.Bytes()
+
+	return result, nil
+}
+
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Bounds().Min == (image.Point{}) {
+		return rgba
+	}
+
+	bounds := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Src)
+	return rgba
+}
+
+// scaleToFit shrinks img so its longest side is at most size by averaging
+// the source pixels covered by each target pixel. Smaller images are
+// returned as they are.
+func scaleToFit(img image.Image, size int) image.Image {
+	srcW, srcH := img.Bounds().Dx(), img.Bounds().Dy()
+	if srcW <= size && srcH <= size {
+		return img
+	}
+
+	dstW, dstH := size, size
+	if srcW > srcH {
+		dstH = max(1, srcH*size/srcW)
+	} else {
+		dstW = max(1, srcW*size/srcH)
+	}
+
+	src := toRGBA(img)
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+
+	for y := 0; y < dstH; y++ {
+		y0, y1 := y*srcH/dstH, max((y+1)*srcH/dstH, y*srcH/dstH+1)
+		for x := 0; x < dstW; x++ {
+			x0, x1 := x*srcW/dstW, max((x+1)*srcW/dstW, x*srcW/dstW+1)
+
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					for c := 0; c < 4; c++ {
+						sum[c] += int(row[sx*4+c])
+					}
+				}
+			}
+
+			count := (y1 - y0) * (x1 - x0)
+			offset := y*dst.Stride + x*4
+			for c := 0; c < 4; c++ {
+				dst.Pix[offset+c] = uint8(sum[c] / count)
+			}
+		}
+	}
+
+	return dst
+}
+
+// applyOrientation transforms img according to an EXIF orientation value,
+// 1 means the image is already upright.
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	src := toRGBA(img)
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+
+	dstW, dstH := w, h
+	if orientation >= 5 {
+		dstW, dstH = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+
+	for y := 0; y < dstH; y++ {
+		for x := 0; x < dstW; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // mirrored horizontally
+				sx, sy = w-1-x, y
+			case 3: // rotated by 180°
+				sx, sy = w-1-x, h-1-y
+			case 4: // mirrored vertically
+				sx, sy = x, h-1-y
+			case 5: // transposed
+				sx, sy = y, x
+			case 6: // needs a 90° clockwise rotation
+				sx, sy = y, h-1-x
+			case 7: // transversed
+				sx, sy = w-1-y, h-1-x
+			case 8: // needs a 90° counter clockwise rotation
+				sx, sy = w-1-y, x
+			}
+			copy(dst.Pix[y*dst.Stride+x*4:y*dst.Stride+x*4+4], src.Pix[sy*src.Stride+sx*4:])
+		}
+	}
+
+	return dst
+}
+
+// gifFrames walks the blocks of a GIF file without decoding them and returns
+// the number of frames and the sum of their pixels. It stops counting once
+// either exceeds its limit.
+func gifFrames(data []byte) (int, int64, error) {
+	errTruncated := errors.New("gif: unexpected end of data")
+
+	// header and logical screen descriptor
+	if len(data) < 13 {
+		return 0, 0, errTruncated
+	}
+	idx := 13
+	if data[10]&0x80 != 0 {
+		idx += 3 << (data[10]&0x07 + 1)
+	}
+
+	// skipSubBlocks moves idx past a sequence of data sub-blocks
+	skipSubBlocks := func() error {
+		for {
+			if idx >= len(data) {
+				return errTruncated
+			}
+			size := int(data[idx])
+			idx++
+			if size == 0 {
+				return nil
+			}
+			idx += size
+		}
+	}
+
+	frames := 0
+	var pixels int64
+	for frames <= MaxGIFFrames && pixels <= MaxGIFPixels {
+		if idx >= len(data) {
+			// the trailer is optional for decoding as well
+			if frames > 0 {
+				return frames, pixels, nil
+			}
+			return 0, 0, errTruncated
+		}
+
+		switch data[idx] {
+		case 0x21: // extension, label and sub-blocks
+			idx += 2
+			err := skipSubBlocks()
+			if err != nil {
+				return 0, 0, err
+			}
+
+		case 0x2C: // image descriptor, optional color table and image data
+			if idx+10 > len(data) {
+				return 0, 0, errTruncated
+			}
+			width := int64(binary.LittleEndian.Uint16(data[idx+5:]))
+			height := int64(binary.LittleEndian.Uint16(data[idx+7:]))
+			packed := data[idx+9]
+			idx += 10
+			if packed&0x80 != 0 {
+				idx += 3 << (packed&0x07 + 1)
+			}
+			// LZW minimum code size
+			idx++
+			err := skipSubBlocks()
+			if err != nil {
+				return 0, 0, err
+			}
+
+			frames++
+			pixels += width * height
+
+		case 0x3B: // trailer
+			return frames, pixels, nil
+
+		default:
+			return 0, 0, fmt.Errorf("gif: unknown block type 0x%02x", data[idx])
+		}
+	}
+
+	return frames, pixels, nil
+}
+
+// jpegOrientation reads the orientation tag from the EXIF segment of a JPEG
+// file, returning 1 if there is none.
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+
+	idx := 2
+	for idx+4 <= len(data) {
+		if data[idx] != 0xFF {
+			return 1
+		}
+		marker := data[idx+1]
+		if marker == 0xFF {
+			// fill byte before a marker
+			idx++
+			continue
+		}
+		if marker == 0xDA || marker == 0xD9 {
+			// metadata is only stored before the image data
+			return 1
+		}
+
+		length := int(binary.BigEndian.Uint16(data[idx+2:]))
+		if length < 2 || idx+2+length > len(data) {
+			return 1
+		}
+
+		segment := data[idx+4 : idx+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+
+		idx += 2 + length
+	}
+
+	return 1
+}
+
+// tiffOrientation looks for tag 0x0112 in the first IFD of TIFF data.
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	offset := int(order.Uint32(tiff[4:]))
+	if offset < 8 || offset+2 > len(tiff) {
+		return 1
+	}
+
+	count := int(order.Uint16(tiff[offset:]))
+	for n := 0; n < count; n++ {
+		entry := offset + 2 + n*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			orientation := int(order.Uint16(tiff[entry+8:]))
+			if orientation < 1 || orientation > 8 {
+				return 1
+			}
+			return orientation
+		}
+	}
+
+	return 1
+}
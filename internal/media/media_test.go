@@ -0,0 +1,206 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"testing"
+)
+
+func testImage(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 100, A: 255})
+		}
+	}
+	return img
+}
+
+// withExifOrientation inserts an APP1 segment with only an orientation tag
+// right after the start of a JPEG file.
+func withExifOrientation(data []byte, orientation byte) []byte {
+	tiff := []byte{
+		'M', 'M', 0x00, 0x2A, 0x00, 0x00, 0x00, 0x08,
+		0x00, 0x01,
+		0x01, 0x12, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, 0x00, orientation, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00,
+	}
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	length := len(payload) + 2
+
+	segment := append([]byte{0xFF, 0xE1, byte(length >> 8), byte(length)}, payload...)
+	result := append([]byte{}, data[:2]...)
+	result = append(result, segment...)
+	return append(result, data[2:]...)
+}
+
+func TestProcessImageStripsExif(t *testing.T) {
+	encoded := bytes.Buffer{}
+	err := jpeg.Encode(&encoded, testImage(40, 20), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := withExifOrientation(encoded.Bytes(), 6)
+
+	if jpegOrientation(data) != 6 {
+		t.Fatalf("Test jpegOrientation failed: expected 6, got %d", jpegOrientation(data))
+	}
+
+	result, err := ProcessImage(data)
+	if err != nil {
+		t.Fatalf("Test ProcessImage failed with err: %v", err)
+	}
+
+	if bytes.Contains(result.Data, []byte("Exif")) {
+		t.Error("Test ProcessImage failed: EXIF data was not stripped")
+	}
+	if result.ContentType != "image/jpeg" || result.Extension != ".jpg" {
+		t.Errorf("Test ProcessImage failed: unexpected type %q %q", result.ContentType, result.Extension)
+	}
+	// rotated by 90° so width and height are swapped
+	if result.Width != 20 || result.Height != 40 {
+		t.Errorf("Test ProcessImage failed: expected 20x40, got %dx%d", result.Width, result.Height)
+	}
+}
+
+func TestProcessImageThumbnail(t *testing.T) {
+	encoded := bytes.Buffer{}
+	err := png.Encode(&encoded, testImage(640, 160))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := ProcessImage(encoded.Bytes())
+	if err != nil {
+		t.Fatalf("Test ProcessImage failed with err: %v", err)
+	}
+
+	thumbnail, err := png.Decode(bytes.NewReader(result.Thumbnail))
+	if err != nil {
+		t.Fatalf("Test ProcessImage failed: thumbnail is not a png: %v", err)
+	}
+
+	bounds := thumbnail.Bounds()
+	if bounds.Dx() != ThumbnailSize || bounds.Dy() != ThumbnailSize/4 {
+		t.Errorf("Test ProcessImage failed: expected %dx%d thumbnail, got %dx%d", ThumbnailSize, ThumbnailSize/4, bounds.Dx(), bounds.Dy())
+	}
+}
+
+// testGIF encodes an animation with frames frames of width x height pixels.
+func testGIF(t *testing.T, frames, width, height int) []byte {
+	t.Helper()
+
+	animation := &gif.GIF{}
+	for range frames {
+		frame := image.NewPaletted(image.Rect(0, 0, width, height), color.Palette{color.Black, color.White})
+		animation.Image = append(animation.Image, frame)
+		animation.Delay = append(animation.Delay, 10)
+	}
+
+	encoded := bytes.Buffer{}
+	err := gif.EncodeAll(&encoded, animation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encoded.Bytes()
+}
+
+func TestProcessImageGIF(t *testing.T) {
+	data := testGIF(t, 3, 30, 20)
+
+	frames, pixels, err := gifFrames(data)
+	if err != nil || frames != 3 || pixels != 3*30*20 {
+		t.Fatalf("Test gifFrames failed: expected 3 frames and %d pixels, got %d, %d, %v", 3*30*20, frames, pixels, err)
+	}
+
+	result, err := ProcessImage(data)
+	if err != nil {
+		t.Fatalf("Test ProcessImage failed with err: %v", err)
+	}
+	if result.ContentType != "image/gif" || result.Width != 30 || result.Height != 20 {
+		t.Errorf("Test ProcessImage failed: unexpected result %q %dx%d", result.ContentType, result.Width, result.Height)
+	}
+}
+
+func TestProcessImageGIFLimits(t *testing.T) {
+	tests := map[string][]byte{
+		"too many frames": testGIF(t, MaxGIFFrames+1, 1, 1),
+		"too many pixels": testGIF(t, MaxGIFPixels/(MaxDimension*MaxDimension)+1, MaxDimension, MaxDimension),
+	}
+
+	for name, data := range tests {
+		_, err := ProcessImage(data)
+		if err == nil {
+			t.Errorf("Test ProcessImage %s: expected an error", name)
+		}
+	}
+}
+
+func TestProcessImageUnsupported(t *testing.T) {
+	for _, input := range [][]byte{[]byte("just some text"), []byte("<svg></svg>"), {}} {
+		_, err := ProcessImage(input)
+		if !errors.Is(err, ErrUnsupportedType) {
+			t.Errorf("Test ProcessImage(%q) failed: expected ErrUnsupportedType, got %v", input, err)
+		}
+	}
+}
+
+func TestApplyOrientation(t *testing.T) {
+	src := testImage(3, 2)
+
+	rotated := toRGBA(applyOrientation(src, 6))
+	if rotated.Bounds().Dx() != 2 || rotated.Bounds().Dy() != 3 {
+		t.Fatalf("Test applyOrientation failed: expected 2x3, got %v", rotated.Bounds())
+	}
+	// the bottom left pixel ends up top left after a clockwise rotation
+	if rotated.RGBAAt(0, 0) != src.RGBAAt(0, 1) {
+		t.Errorf("Test applyOrientation failed: expected %v, got %v", src.RGBAAt(0, 1), rotated.RGBAAt(0, 0))
+	}
+}
+
+func TestLocalStorage(t *testing.T) {
+	storage, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	err = storage.Put(ctx, "image.png", bytes.NewReader([]byte("data")))
+	if err != nil {
+		t.Fatalf("Test Put failed with err: %v", err)
+	}
+
+	file, err := storage.Open(ctx, "image.png")
+	if err != nil {
+		t.Fatalf("Test Open failed with err: %v", err)
+	}
+	content, _ := io.ReadAll(file)
+	file.Close()
+	if string(content) != "data" {
+		t.Errorf("Test Open failed: expected %q, got %q", "data", content)
+	}
+
+	err = storage.Delete(ctx, "image.png")
+	if err != nil {
+		t.Fatalf("Test Delete failed with err: %v", err)
+	}
+
+	_, err = storage.Open(ctx, "image.png")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Test Open failed: expected ErrNotFound, got %v", err)
+	}
+
+	for _, key := range []string{"../escape", "a/b", "", ".hidden"} {
+		err = storage.Put(ctx, key, bytes.NewReader(nil))
+		if err == nil {
+			t.Errorf("Test Put(%q) failed: expected an error", key)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// ErrNotFound is returned when opening a key that has never been stored.
+var ErrNotFound = errors.New("media: object not found")
+
+// Storage keeps uploaded files by key. Keys are generated by the server and
+// only ever contain letters, digits, dashes, underscores and dots.
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader) error
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+var keyPattern = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9._-]*$`)
+
+// LocalStorage stores files in a directory on the local disk.
+type LocalStorage struct {
+	root string
+}
+
+// NewLocalStorage uses root for storage, creating it if it does not exist.
+func NewLocalStorage(root string) (*LocalStorage, error) {
+	err := os.MkdirAll(root, 0o755)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LocalStorage{root: root}, nil
+}
+
+func (s *LocalStorage) path(key string) (string, error) {
+	if !keyPattern.MatchString(key) {
+		return "", errors.New("media: invalid key " + key)
+	}
+
+	return filepath.Join(s.root, key), nil
+}
+
+// Put writes to a temporary file first so readers never see a partial file.
+func (s *LocalStorage) Put(ctx context.Context, key string, r io.Reader) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.root, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *LocalStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+
+	return file, err
+}
+
+// Delete does not fail if key does not exist.
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,130 @@
+package moderation
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Config lists the filters of a pipeline in the order they are applied, e.g.
+//
+//	{"filters": [
+//	  {"name": "profanity", "type": "wordlist", "file": "profanity.txt", "action": "mask"},
+//	  {"name": "banned", "type": "wordlist", "database": true, "action": "reject"},
+//	  {"name": "links", "type": "regex", "pattern": "https?://", "action": "flag"}
+//	]}
+type Config struct {
+	Filters []FilterConfig `json:"filters"`
+}
+
+type FilterConfig struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Action Action `json:"action"`
+
+	// word lists are read from any combination of these
+	Words    []string `json:"words"`
+	File     string   `json:"file"`
+	Database bool     `json:"database"`
+
+	Pattern string `json:"pattern"`
+}
+
+// WordLoader returns the words stored in the database.
+type WordLoader func() ([]string, error)
+
+// DefaultConfig masks the words chirpy has always masked.
+func DefaultConfig() Config {
+	return Config{
+		Filters: []FilterConfig{
+			{
+				Name:   "profanity",
+				Type:   "wordlist",
+				Action: ActionMask,
+				Words:  []string{"kerfuffle", "sharbert", "fornax"},
+			},
+		},
+	}
+}
+
+func LoadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+
+	config := Config{}
+	err = json.Unmarshal(data, &config)
+	if err != nil {
+		return Config{}, fmt.Errorf("Error parsing moderation config %s: %w", path, err)
+	}
+
+	return config, nil
+}
+
+// NewPipelineFromConfig builds the filters described by config, loadWords is
+// only called for word lists with "database" set.
+func NewPipelineFromConfig(config Config, loadWords WordLoader) (*Pipeline, error) {
+	filters := []Filter{}
+
+	for idx, filterCfg := range config.Filters {
+		if filterCfg.Name == "" {
+			filterCfg.Name = fmt.Sprintf("%s-%d", filterCfg.Type, idx)
+		}
+
+		err := filterCfg.Action.Validate()
+		if err != nil {
+			return nil, fmt.Errorf("Filter %s: %w", filterCfg.Name, err)
+		}
+
+		switch filterCfg.Type {
+		case "wordlist":
+			words, err := filterCfg.loadWords(loadWords)
+			if err != nil {
+				return nil, fmt.Errorf("Filter %s: %w", filterCfg.Name, err)
+			}
+			filters = append(filters, NewWordListFilter(filterCfg.Name, filterCfg.Action, words))
+		case "regex":
+			filter, err := NewRegexFilter(filterCfg.Name, filterCfg.Action, filterCfg.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("Filter %s: %w", filterCfg.Name, err)
+			}
+			filters = append(filters, filter)
+		default:
+			return nil, fmt.Errorf("Filter %s: unknown filter type %q", filterCfg.Name, filterCfg.Type)
+		}
+	}
+
+	return NewPipeline(filters...), nil
+}
+
+func (f FilterConfig) loadWords(loadWords WordLoader) ([]string, error) {
+	words := append([]string{}, f.Words...)
+
+	if f.File != "" {
+		fileWords, err := ReadWordListFile(f.File)
+		if err != nil {
+			return nil, err
+		}
+		words = append(words, fileWords...)
+	}
+
+	if f.Database {
+		if loadWords == nil {
+			return nil, fmt.Errorf("no database to load words from")
+		}
+		dbWords, err := loadWords()
+		if err != nil {
+			return nil, err
+		}
+		words = append(words, dbWords...)
+	}
+
+	for _, word := range words {
+		if !validWord(word) {
+			return nil, fmt.Errorf("%q would never match, word lists can only contain single words without punctuation", word)
+		}
+	}
+
+	return words, nil
+}
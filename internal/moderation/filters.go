@@ -0,0 +1,138 @@
+package moderation
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// WordListFilter matches whole words case-insensitively, ignoring the
+// punctuation around them, so "Kerfuffle!" matches the word "kerfuffle".
+type WordListFilter struct {
+	name   string
+	action Action
+	words  map[string]struct{}
+}
+
+func NewWordListFilter(name string, action Action, words []string) *WordListFilter {
+	filter := &WordListFilter{
+		name:   name,
+		action: action,
+		words:  map[string]struct{}{},
+	}
+	for _, word := range words {
+		word = strings.ToLower(strings.TrimSpace(word))
+		if word != "" {
+			filter.words[word] = struct{}{}
+		}
+	}
+
+	return filter
+}
+
+func (f *WordListFilter) Name() string   { return f.name }
+func (f *WordListFilter) Action() Action { return f.action }
+
+func (f *WordListFilter) Find(body string) []Match {
+	matches := []Match{}
+
+	start := -1
+	for idx, r := range body + " " {
+		inWord := isWordRune(r)
+		if inWord && start == -1 {
+			start = idx
+			continue
+		}
+		if !inWord && start != -1 {
+			if _, exists := f.words[strings.ToLower(body[start:idx])]; exists {
+				matches = append(matches, Match{Start: start, End: idx})
+			}
+			start = -1
+		}
+	}
+
+	return matches
+}
+
+// isWordRune reports whether r is part of a word, everything else separates
+// words.
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// validWord reports whether word can be matched by a WordListFilter, which
+// only ever compares single words.
+func validWord(word string) bool {
+	for _, r := range strings.TrimSpace(word) {
+		if !isWordRune(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// ReadWordList reads one word per line, skipping empty lines and lines
+// starting with '#'.
+func ReadWordList(reader io.Reader) ([]string, error) {
+	words := []string{}
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words = append(words, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return words, nil
+}
+
+func ReadWordListFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadWordList(file)
+}
+
+// RegexFilter matches a regular expression anywhere in the chirp.
+type RegexFilter struct {
+	name    string
+	action  Action
+	pattern *regexp.Regexp
+}
+
+func NewRegexFilter(name string, action Action, pattern string) (*RegexFilter, error) {
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RegexFilter{name: name, action: action, pattern: compiled}, nil
+}
+
+func (f *RegexFilter) Name() string   { return f.name }
+func (f *RegexFilter) Action() Action { return f.action }
+
+func (f *RegexFilter) Find(body string) []Match {
+	matches := []Match{}
+	for _, loc := range f.pattern.FindAllStringIndex(body, -1) {
+		// empty matches would insert masks between characters
+		if loc[0] == loc[1] {
+			continue
+		}
+		matches = append(matches, Match{Start: loc[0], End: loc[1]})
+	}
+
+	return matches
+}
@@ -0,0 +1,119 @@
+package moderation
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Action decides what happens to a chirp once a filter matches it.
+type Action string
+
+const (
+	// ActionMask replaces every match with asterisks.
+	ActionMask Action = "mask"
+	// ActionReject refuses to store the chirp.
+	ActionReject Action = "reject"
+	// ActionFlag stores the chirp unchanged but queues it for review.
+	ActionFlag Action = "flag"
+)
+
+func (a Action) Validate() error {
+	switch a {
+	case ActionMask, ActionReject, ActionFlag:
+		return nil
+	}
+	return fmt.Errorf("Unknown moderation action %q", a)
+}
+
+const mask = "****"
+
+// Match is a byte range of the checked text, End is exclusive.
+type Match struct {
+	Start int
+	End   int
+}
+
+// Filter finds content in a chirp that should be moderated.
+type Filter interface {
+	Name() string
+	Action() Action
+	Find(body string) []Match
+}
+
+// Result describes what the pipeline decided for a single chirp.
+type Result struct {
+	// Body with all matches of masking filters replaced
+	Body     string
+	Rejected bool
+	Flagged  bool
+	// names of the filters that rejected or flagged the chirp
+	Reasons []string
+}
+
+// Pipeline runs a chirp through all of its filters in order.
+type Pipeline struct {
+	filters []Filter
+}
+
+func NewPipeline(filters ...Filter) *Pipeline {
+	return &Pipeline{filters: filters}
+}
+
+// Moderate applies every filter to body. Rejecting filters win over all
+// others, so as soon as one matches the result is returned without running
+// the remaining filters.
+func (p *Pipeline) Moderate(body string) Result {
+	result := Result{Body: body}
+
+	for _, filter := range p.filters {
+		matches := filter.Find(result.Body)
+		if len(matches) == 0 {
+			continue
+		}
+
+		switch filter.Action() {
+		case ActionMask:
+			result.Body = applyMask(result.Body, matches)
+		case ActionReject:
+			result.Rejected = true
+			result.Reasons = append(result.Reasons, filter.Name())
+			return result
+		case ActionFlag:
+			result.Flagged = true
+			result.Reasons = append(result.Reasons, filter.Name())
+		}
+	}
+
+	return result
+}
+
+// RejectionError is returned to clients when a chirp was rejected.
+func (r Result) RejectionError() error {
+	if !r.Rejected {
+		return nil
+	}
+	return errors.New("Chirp rejected by filter: " + strings.Join(r.Reasons, ", "))
+}
+
+func applyMask(body string, matches []Match) string {
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Start < matches[j].Start
+	})
+
+	var builder strings.Builder
+	last := 0
+	for _, match := range matches {
+		// skip matches overlapping an already masked range
+		if match.Start < last {
+			continue
+		}
+		builder.WriteString(body[last:match.Start])
+		builder.WriteString(mask)
+		last = match.End
+	}
+	builder.WriteString(body[last:])
+
+	return builder.String()
+}
@@ -0,0 +1,96 @@
+package moderation
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWordListFilterMask(t *testing.T) {
+	pipeline := NewPipeline(NewWordListFilter("profanity", ActionMask, []string{"kerfuffle", "Sharbert"}))
+
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{input: "what a kerfuffle", expected: "what a ****"},
+		{input: "KERFUFFLE!", expected: "****!"},
+		{input: "sharbert, kerfuffle.", expected: "****, ****."},
+		{input: "kerfuffles are fine", expected: "kerfuffles are fine"},
+		{input: "(Sharbert)", expected: "(****)"},
+	}
+
+	for _, c := range cases {
+		result := pipeline.Moderate(c.input)
+		if result.Body != c.expected {
+			t.Errorf("Test WordListFilterMask(%q) failed: expected: %q, got: %q", c.input, c.expected, result.Body)
+		}
+		if result.Rejected || result.Flagged {
+			t.Errorf("Test WordListFilterMask(%q) failed: masking must not reject or flag", c.input)
+		}
+	}
+}
+
+func TestRejectWinsOverFlag(t *testing.T) {
+	links, err := NewRegexFilter("links", ActionFlag, `https?://\S+`)
+	if err != nil {
+		t.Fatalf("Test RejectWinsOverFlag::NewRegexFilter failed with err: %v", err)
+	}
+	pipeline := NewPipeline(links, NewWordListFilter("banned", ActionReject, []string{"fornax"}))
+
+	result := pipeline.Moderate("see http://example.com")
+	if !result.Flagged || result.Rejected || result.Body != "see http://example.com" {
+		t.Errorf("Test RejectWinsOverFlag failed: expected flagged chirp, got: %+v", result)
+	}
+
+	result = pipeline.Moderate("Fornax at http://example.com")
+	if !result.Rejected || result.RejectionError() == nil {
+		t.Errorf("Test RejectWinsOverFlag failed: expected rejected chirp, got: %+v", result)
+	}
+}
+
+func TestReadWordList(t *testing.T) {
+	words, err := ReadWordList(strings.NewReader("# comment\nkerfuffle\n\n  fornax \n"))
+	if err != nil {
+		t.Fatalf("Test ReadWordList failed with err: %v", err)
+	}
+
+	if len(words) != 2 || words[0] != "kerfuffle" || words[1] != "fornax" {
+		t.Errorf("Test ReadWordList failed: got: %v", words)
+	}
+}
+
+func TestNewPipelineFromConfig(t *testing.T) {
+	config := Config{Filters: []FilterConfig{
+		{Name: "db", Type: "wordlist", Action: ActionReject, Database: true},
+	}}
+
+	pipeline, err := NewPipelineFromConfig(config, func() ([]string, error) {
+		return []string{"sharbert"}, nil
+	})
+	if err != nil {
+		t.Fatalf("Test NewPipelineFromConfig failed with err: %v", err)
+	}
+
+	if !pipeline.Moderate("SHARBERT").Rejected {
+		t.Errorf("Test NewPipelineFromConfig failed: expected words from the database to be rejected")
+	}
+
+	config.Filters[0].Action = "delete"
+	_, err = NewPipelineFromConfig(config, nil)
+	if err == nil {
+		t.Errorf("Test NewPipelineFromConfig failed: expected an error for an unknown action")
+	}
+}
+
+func TestNewPipelineFromConfigRejectsPhrases(t *testing.T) {
+	for _, word := range []string{"fork off", "don't", "well-known"} {
+		config := Config{Filters: []FilterConfig{
+			{Name: "inline", Type: "wordlist", Action: ActionMask, Words: []string{"kerfuffle", word}},
+		}}
+
+		_, err := NewPipelineFromConfig(config, nil)
+		if err == nil {
+			t.Errorf("Test NewPipelineFromConfig failed: expected an error for %q", word)
+		}
+	}
+}
@@ -0,0 +1,359 @@
+// Package oidc logs users in with an OpenID Connect provider using the
+// authorization code flow with PKCE.
+package oidc
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Config describes the client registered with the provider.
+type Config struct {
+	// Issuer is the URL the discovery document is fetched from, e.g.
+	// "https://accounts.example.com"
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is where the provider sends the user back to with the code
+	RedirectURL string
+	// Scopes requested on top of "openid"
+	Scopes     []string
+	HTTPClient *http.Client
+}
+
+// discoveryDocument holds the parts of the provider metadata the flow needs.
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// IDToken are the claims of a verified ID token.
+type IDToken struct {
+	jwt.RegisteredClaims
+	Nonce             string `json:"nonce"`
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	Name              string `json:"name"`
+	PreferredUsername string `json:"preferred_username"`
+}
+
+// Provider is an OpenID Connect provider. The discovery document is fetched
+// on first use and the signing keys whenever a token is signed with an unknown
+// key, so the provider does not have to be reachable when the server starts.
+type Provider struct {
+	config Config
+
+	mu       sync.Mutex
+	metadata *discoveryDocument
+	keys     map[string]any
+}
+
+func NewProvider(config Config) *Provider {
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return &Provider{config: config, keys: map[string]any{}}
+}
+
+func (p *Provider) Issuer() string {
+	return p.config.Issuer
+}
+
+func (p *Provider) RedirectURL() string {
+	return p.config.RedirectURL
+}
+
+func (p *Provider) discover(ctx context.Context) (*discoveryDocument, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	wellKnown := strings.TrimSuffix(p.config.Issuer, "/") + "/.well-known/openid-configuration"
+	metadata := &discoveryDocument{}
+	err := p.getJSON(ctx, wellKnown, metadata)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: discovery: %w", err)
+	}
+
+	if metadata.Issuer != p.config.Issuer {
+		return nil, fmt.Errorf("oidc: discovery: issuer %q does not match %q", metadata.Issuer, p.config.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, errors.New("oidc: discovery: endpoints missing")
+	}
+
+	p.metadata = metadata
+	return metadata, nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.config.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+// NewPKCE returns a random code verifier and its S256 code challenge.
+func NewPKCE() (verifier, challenge string, err error) {
+	verifier, err = randomString(32)
+	if err != nil {
+		return "", "", err
+	}
+
+	return verifier, PKCEChallenge(verifier), nil
+}
+
+// PKCEChallenge returns the S256 code challenge of a code verifier.
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// NewState returns a random value to be used as state or nonce.
+func NewState() (string, error) {
+	return randomString(32)
+}
+
+func randomString(numBytes int) (string, error) {
+	bytes := make([]byte, numBytes)
+	_, err := rand.Read(bytes)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+// AuthCodeURL is where the user is sent to log in with the provider.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", p.config.ClientID)
+	query.Set("redirect_uri", p.config.RedirectURL)
+	query.Set("scope", strings.Join(append([]string{"openid"}, p.config.Scopes...), " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", codeChallenge)
+	query.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return metadata.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+type tokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Exchange trades the code the provider redirected back with for an ID token
+// and verifies it.
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*IDToken, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.config.RedirectURL)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	// client_secret_basic, RFC 6749 wants the credentials form encoded first
+	req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+
+	resp, err := p.config.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: token request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	tokenResp := tokenResponse{}
+	err = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&tokenResp)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: token response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc: token request: %s: %s %s", resp.Status, tokenResp.Error, tokenResp.ErrorDescription)
+	}
+	if tokenResp.IDToken == "" {
+		return nil, errors.New("oidc: token response without id_token")
+	}
+
+	return p.VerifyIDToken(ctx, tokenResp.IDToken, nonce)
+}
+
+// VerifyIDToken checks the signature, issuer, audience, expiry and nonce of
+// an ID token.
+func (p *Provider) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (*IDToken, error) {
+	token, err := jwt.ParseWithClaims(
+		rawIDToken,
+		&IDToken{},
+		func(t *jwt.Token) (interface{}, error) {
+			kid, _ := t.Header["kid"].(string)
+			return p.key(ctx, kid)
+		},
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}),
+		jwt.WithIssuer(p.config.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: invalid id token: %w", err)
+	}
+
+	claims := token.Claims.(*IDToken)
+	if claims.Nonce != nonce {
+		return nil, errors.New("oidc: invalid id token: nonce does not match")
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("oidc: invalid id token: no subject")
+	}
+
+	return claims, nil
+}
+
+// key returns the signing key with the given id. The keys of the provider are
+// fetched again if it is not known yet, since the provider may have rotated
+// them.
+func (p *Provider) key(ctx context.Context, kid string) (any, error) {
+	p.mu.Lock()
+	key, ok := p.keys[kid]
+	p.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	jwks := jsonWebKeySet{}
+	err = p.getJSON(ctx, metadata.JWKSURI, &jwks)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: fetching keys: %w", err)
+	}
+
+	keys := map[string]any{}
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		public, err := jwk.publicKey()
+		if err != nil {
+			// keys of unsupported types are skipped
+			continue
+		}
+		keys[jwk.Kid] = public
+	}
+
+	p.mu.Lock()
+	p.keys = keys
+	p.mu.Unlock()
+
+	key, ok = keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("oidc: unknown key id %q", kid)
+	}
+
+	return key, nil
+}
+
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+}
+
+func (k jsonWebKey) publicKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+			return nil, errors.New("RSA exponent too large")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
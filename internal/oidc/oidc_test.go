@@ -0,0 +1,176 @@
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/thewerther/webserver/internal/oidc/oidctest"
+)
+
+const testRedirectURL = "http://chirpy.test/api/auth/oidc/callback"
+
+func newTestProvider(t *testing.T) (*Provider, *oidctest.Provider) {
+	t.Helper()
+
+	var idp *oidctest.Provider
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		idp.ServeHTTP(w, req)
+	}))
+	t.Cleanup(server.Close)
+
+	idp, err := oidctest.New(server.URL, "chirpy", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	provider := NewProvider(Config{
+		Issuer:       server.URL,
+		ClientID:     "chirpy",
+		ClientSecret: "secret",
+		RedirectURL:  testRedirectURL,
+		Scopes:       []string{"email", "profile"},
+	})
+
+	return provider, idp
+}
+
+// authorize follows the login at the fake provider and returns the code it
+// redirects back with.
+func authorize(t *testing.T, provider *Provider, state, nonce, challenge, loginHint string) string {
+	t.Helper()
+
+	authURL, err := provider.AuthCodeURL(context.Background(), state, nonce, challenge)
+	if err != nil {
+		t.Fatalf("AuthCodeURL failed with err: %v", err)
+	}
+	if loginHint != "" {
+		authURL += "&login_hint=" + url.QueryEscape(loginHint)
+	}
+
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize returned %s, expected a redirect", resp.Status)
+	}
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(location.String(), testRedirectURL) {
+		t.Fatalf("redirected to %s, expected %s", location, testRedirectURL)
+	}
+	if location.Query().Get("state") != state {
+		t.Fatalf("state %q was not passed back", state)
+	}
+
+	return location.Query().Get("code")
+}
+
+func TestLoginFlow(t *testing.T) {
+	provider, _ := newTestProvider(t)
+
+	verifier, challenge, err := NewPKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code := authorize(t, provider, "state", "nonce", challenge, "")
+
+	idToken, err := provider.Exchange(context.Background(), code, verifier, "nonce")
+	if err != nil {
+		t.Fatalf("Test LoginFlow::Exchange failed with err: %v", err)
+	}
+
+	if idToken.Subject != "fake-user" || idToken.Email != "user@example.com" || !idToken.EmailVerified {
+		t.Errorf("Test LoginFlow: unexpected claims %+v", idToken)
+	}
+}
+
+func TestLoginHint(t *testing.T) {
+	provider, _ := newTestProvider(t)
+
+	verifier, challenge, err := NewPKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code := authorize(t, provider, "state", "nonce", challenge, "alice@example.com")
+
+	idToken, err := provider.Exchange(context.Background(), code, verifier, "nonce")
+	if err != nil {
+		t.Fatalf("Test LoginHint::Exchange failed with err: %v", err)
+	}
+
+	if idToken.Email != "alice@example.com" || idToken.Subject != "fake-alice@example.com" {
+		t.Errorf("Test LoginHint: unexpected claims %+v", idToken)
+	}
+}
+
+func TestExchangeRejects(t *testing.T) {
+	provider, idp := newTestProvider(t)
+
+	verifier, challenge, err := NewPKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		verifier string
+		nonce    string
+	}{
+		"wrong verifier": {verifier: verifier + "x", nonce: "nonce"},
+		"wrong nonce":    {verifier: verifier, nonce: "other"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			code := authorize(t, provider, "state", "nonce", challenge, "")
+
+			_, err := provider.Exchange(context.Background(), code, tc.verifier, tc.nonce)
+			if err == nil {
+				t.Errorf("Test ExchangeRejects %s: expected an error", name)
+			}
+		})
+	}
+
+	t.Run("reused code", func(t *testing.T) {
+		code := authorize(t, provider, "state", "nonce", challenge, "")
+
+		_, err := provider.Exchange(context.Background(), code, verifier, "nonce")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = provider.Exchange(context.Background(), code, verifier, "nonce")
+		if err == nil {
+			t.Errorf("Test ExchangeRejects: code could be used twice")
+		}
+	})
+
+	t.Run("other audience", func(t *testing.T) {
+		rawIDToken, err := idp.IDToken(idp.DefaultUser, "nonce")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		other := NewProvider(Config{Issuer: idp.Issuer, ClientID: "someone-else"})
+		_, err = other.VerifyIDToken(context.Background(), rawIDToken, "nonce")
+		if err == nil {
+			t.Errorf("Test ExchangeRejects: token for another client was accepted")
+		}
+	})
+}
@@ -0,0 +1,316 @@
+// Package oidctest is a fake OpenID Connect provider for tests and local
+// development. It logs everyone in without asking, as the user given by the
+// `login_hint` of the authorization request or the default user otherwise.
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	codeExpiry    = time.Minute
+	idTokenExpiry = 5 * time.Minute
+	keyID         = "oidctest"
+)
+
+// User is who the provider logs in.
+type User struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+}
+
+type authRequest struct {
+	user          User
+	clientID      string
+	redirectURI   string
+	nonce         string
+	codeChallenge string
+	expiresAt     time.Time
+}
+
+// Provider is an http.Handler serving the discovery document, the
+// authorization, token and keys endpoints below the path of Issuer.
+type Provider struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// DefaultUser is logged in if the authorization request has no
+	// login_hint
+	DefaultUser User
+
+	key *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]authRequest
+}
+
+func New(issuer, clientID, clientSecret string) (*Provider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Provider{
+		Issuer:       strings.TrimSuffix(issuer, "/"),
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		DefaultUser: User{
+			Subject:           "fake-user",
+			Email:             "user@example.com",
+			EmailVerified:     true,
+			Name:              "Fake User",
+			PreferredUsername: "fake_user",
+		},
+		key:   key,
+		codes: map[string]authRequest{},
+	}, nil
+}
+
+// UserForHint is the user logged in for a login_hint, it is taken as the
+// email address.
+func UserForHint(hint string) User {
+	name, _, _ := strings.Cut(hint, "@")
+	return User{
+		Subject:           "fake-" + hint,
+		Email:             hint,
+		EmailVerified:     true,
+		Name:              name,
+		PreferredUsername: name,
+	}
+}
+
+func (p *Provider) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	issuerURL, err := url.Parse(p.Issuer)
+	if err != nil {
+		http.Error(w, "invalid issuer", http.StatusInternalServerError)
+		return
+	}
+
+	switch strings.TrimPrefix(req.URL.Path, issuerURL.Path) {
+	case "/.well-known/openid-configuration":
+		p.serveDiscovery(w)
+	case "/authorize":
+		p.authorize(w, req)
+	case "/token":
+		p.token(w, req)
+	case "/jwks":
+		p.serveJWKS(w)
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+func randomString() (string, error) {
+	bytes := make([]byte, 32)
+	_, err := rand.Read(bytes)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeTokenError(w http.ResponseWriter, code int, errorCode, description string) {
+	writeJSON(w, code, map[string]string{"error": errorCode, "error_description": description})
+}
+
+func (p *Provider) serveDiscovery(w http.ResponseWriter) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                p.Issuer,
+		"authorization_endpoint":                p.Issuer + "/authorize",
+		"token_endpoint":                        p.Issuer + "/token",
+		"jwks_uri":                              p.Issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (p *Provider) serveJWKS(w http.ResponseWriter) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"kid": keyID,
+			"n":   base64.RawURLEncoding.EncodeToString(p.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.key.E)).Bytes()),
+		}},
+	})
+}
+
+// authorize logs the user in right away and redirects back with a code.
+func (p *Provider) authorize(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+
+	if query.Get("client_id") != p.ClientID {
+		http.Error(w, "unknown client_id", http.StatusBadRequest)
+		return
+	}
+
+	redirectURI, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || !redirectURI.IsAbs() {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	if query.Get("response_type") != "code" {
+		http.Error(w, "only response_type=code is supported", http.StatusBadRequest)
+		return
+	}
+
+	if query.Get("code_challenge") == "" || query.Get("code_challenge_method") != "S256" {
+		http.Error(w, "PKCE with S256 is required", http.StatusBadRequest)
+		return
+	}
+
+	user := p.DefaultUser
+	if hint := query.Get("login_hint"); hint != "" {
+		user = UserForHint(hint)
+	}
+
+	code, err := randomString()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	p.mu.Lock()
+	p.codes[code] = authRequest{
+		user:          user,
+		clientID:      query.Get("client_id"),
+		redirectURI:   redirectURI.String(),
+		nonce:         query.Get("nonce"),
+		codeChallenge: query.Get("code_challenge"),
+		expiresAt:     time.Now().Add(codeExpiry),
+	}
+	p.mu.Unlock()
+
+	redirectQuery := redirectURI.Query()
+	redirectQuery.Set("code", code)
+	redirectQuery.Set("state", query.Get("state"))
+	redirectURI.RawQuery = redirectQuery.Encode()
+
+	http.Redirect(w, req, redirectURI.String(), http.StatusFound)
+}
+
+// token exchanges a code for an ID token. Codes can only be used once.
+func (p *Provider) token(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		writeTokenError(w, http.StatusMethodNotAllowed, "invalid_request", "POST required")
+		return
+	}
+
+	err := req.ParseForm()
+	if err != nil {
+		writeTokenError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	clientID, clientSecret, ok := req.BasicAuth()
+	if ok {
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID = req.PostForm.Get("client_id")
+		clientSecret = req.PostForm.Get("client_secret")
+	}
+	if clientID != p.ClientID || subtle.ConstantTimeCompare([]byte(clientSecret), []byte(p.ClientSecret)) != 1 {
+		writeTokenError(w, http.StatusUnauthorized, "invalid_client", "unknown client or wrong secret")
+		return
+	}
+
+	if req.PostForm.Get("grant_type") != "authorization_code" {
+		writeTokenError(w, http.StatusBadRequest, "unsupported_grant_type", "only authorization_code is supported")
+		return
+	}
+
+	code := req.PostForm.Get("code")
+	p.mu.Lock()
+	authReq, ok := p.codes[code]
+	delete(p.codes, code)
+	p.mu.Unlock()
+
+	if !ok || time.Now().After(authReq.expiresAt) || authReq.clientID != clientID {
+		writeTokenError(w, http.StatusBadRequest, "invalid_grant", "unknown or expired code")
+		return
+	}
+
+	if req.PostForm.Get("redirect_uri") != authReq.redirectURI {
+		writeTokenError(w, http.StatusBadRequest, "invalid_grant", "redirect_uri does not match")
+		return
+	}
+
+	challenge := sha256.Sum256([]byte(req.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(challenge[:]) != authReq.codeChallenge {
+		writeTokenError(w, http.StatusBadRequest, "invalid_grant", "code_verifier does not match")
+		return
+	}
+
+	idToken, err := p.IDToken(authReq.user, authReq.nonce)
+	if err != nil {
+		writeTokenError(w, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+
+	accessToken, err := randomString()
+	if err != nil {
+		writeTokenError(w, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(idTokenExpiry.Seconds()),
+		"id_token":     idToken,
+	})
+}
+
+// IDToken returns an ID token for user signed by the provider, tests can use
+// it to check how invalid tokens are handled.
+func (p *Provider) IDToken(user User, nonce string) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":                p.Issuer,
+		"sub":                user.Subject,
+		"aud":                p.ClientID,
+		"iat":                now.Unix(),
+		"exp":                now.Add(idTokenExpiry).Unix(),
+		"email":              user.Email,
+		"email_verified":     user.EmailVerified,
+		"name":               user.Name,
+		"preferred_username": user.PreferredUsername,
+	}
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = keyID
+
+	return token.SignedString(p.key)
+}
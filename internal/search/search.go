@@ -0,0 +1,74 @@
+package search
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+// BuildTSQuery turns free text entered by a user into a query string for
+// Postgres' to_tsquery. Words are AND'ed together, text in double quotes is
+// matched as a phrase and a trailing `*` matches a word by prefix, e.g.
+//
+//	big "red dog" kerf*  =>  big & red <-> dog & kerf:*
+//
+// Everything that is not a letter or a digit is dropped, so the result is
+// always safe to hand to to_tsquery.
+func BuildTSQuery(input string) (string, error) {
+	terms := []string{}
+
+	segments := strings.Split(input, "\"")
+	for idx, segment := range segments {
+		// every odd segment was enclosed in quotes
+		if idx%2 == 1 {
+			if phrase := buildPhrase(segment); phrase != "" {
+				terms = append(terms, phrase)
+			}
+			continue
+		}
+
+		for _, word := range strings.Fields(segment) {
+			if term := buildTerm(word); term != "" {
+				terms = append(terms, term)
+			}
+		}
+	}
+
+	if len(terms) == 0 {
+		return "", errors.New("Search query does not contain any words")
+	}
+
+	return strings.Join(terms, " & "), nil
+}
+
+func buildPhrase(phrase string) string {
+	lexemes := []string{}
+	for _, word := range strings.Fields(phrase) {
+		lexemes = append(lexemes, splitLexemes(word)...)
+	}
+
+	return strings.Join(lexemes, " <-> ")
+}
+
+func buildTerm(word string) string {
+	isPrefix := strings.HasSuffix(word, "*")
+
+	lexemes := splitLexemes(word)
+	if len(lexemes) == 0 {
+		return ""
+	}
+
+	if isPrefix {
+		lexemes[len(lexemes)-1] += ":*"
+	}
+
+	// words like "e-mail" are split up by to_tsvector as well, so match
+	// their parts as a phrase
+	return strings.Join(lexemes, " <-> ")
+}
+
+func splitLexemes(word string) []string {
+	return strings.FieldsFunc(strings.ToLower(word), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
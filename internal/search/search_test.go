@@ -0,0 +1,40 @@
+package search
+
+import "testing"
+
+func TestBuildTSQuery(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{input: "hello", expected: "hello"},
+		{input: "Hello World", expected: "hello & world"},
+		{input: "\"big red dog\"", expected: "big <-> red <-> dog"},
+		{input: "kerf*", expected: "kerf:*"},
+		{input: "go \"red dog\" kerf*", expected: "go & red <-> dog & kerf:*"},
+		{input: "e-mail", expected: "e <-> mail"},
+		{input: "drop'); DELETE", expected: "drop & delete"},
+		{input: "hello:* | !world", expected: "hello:* & world"},
+	}
+
+	for _, c := range cases {
+		actual, err := BuildTSQuery(c.input)
+		if err != nil {
+			t.Errorf("Test BuildTSQuery(%q) failed with err: %v", c.input, err)
+			continue
+		}
+
+		if actual != c.expected {
+			t.Errorf("Test BuildTSQuery(%q) failed: expected: %q, got: %q", c.input, c.expected, actual)
+		}
+	}
+}
+
+func TestBuildTSQueryEmpty(t *testing.T) {
+	for _, input := range []string{"", "   ", "\"\"", "*", "!?"} {
+		_, err := BuildTSQuery(input)
+		if err == nil {
+			t.Errorf("Test BuildTSQueryEmpty(%q) failed: expected an error", input)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"os"
+
+	"github.com/thewerther/webserver/internal/auth"
+)
+
+// loadJWTKeys signs access tokens with the keys in JWT_KEYS_DIR if it is set,
+// JWT_SIGNING_KEY_ID picks the current key. Otherwise JWT_SECRET is used as
+// HS256 secret.
+func loadJWTKeys() (*auth.KeySet, error) {
+	keysDir := os.Getenv("JWT_KEYS_DIR")
+	if keysDir != "" {
+		return auth.LoadKeySet(keysDir, os.Getenv("JWT_SIGNING_KEY_ID"))
+	}
+
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		return nil, errors.New("either JWT_KEYS_DIR or JWT_SECRET has to be set")
+	}
+
+	return auth.NewHMACKeySet(secret)
+}
+
+// serveJWKS publishes the public keys access tokens are signed with, so other
+// services can validate them without sharing a secret.
+func (cfg *ApiConfig) serveJWKS(w http.ResponseWriter, req *http.Request) {
+	// keys change rarely, but a rotation should be picked up soon
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	respondWithJSON(w, http.StatusOK, cfg.JWTKeys.JWKS())
+}
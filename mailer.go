@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/thewerther/webserver/internal/auth"
+	"github.com/thewerther/webserver/internal/database"
+	"github.com/thewerther/webserver/internal/mail"
+)
+
+const (
+	emailVerificationExpiry = 24 * time.Hour
+	passwordResetExpiry     = time.Hour
+	// no new reset email is sent while the last one is younger than this
+	passwordResetInterval = 5 * time.Minute
+)
+
+// loadMailer sends emails through SMTP_ADDR if it is set. Otherwise emails
+// are appended to MAIL_FILE, or written to the log if that is not set either.
+func loadMailer() (mail.Mailer, error) {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "chirpy@localhost"
+	}
+
+	smtpAddr := os.Getenv("SMTP_ADDR")
+	if smtpAddr != "" {
+		return mail.NewSMTPMailer(smtpAddr, from, os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"))
+	}
+
+	mailFile := os.Getenv("MAIL_FILE")
+	if mailFile != "" {
+		return mail.NewFileMailer(mailFile, from)
+	}
+
+	return mail.NewWriterMailer(log.Writer(), from), nil
+}
+
+// sendVerificationEmail emails a new verification token to the current
+// address of user, replacing any token sent before.
+func (cfg *ApiConfig) sendVerificationEmail(ctx context.Context, user database.User) error {
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		return err
+	}
+
+	err = cfg.Database.CreateEmailVerificationToken(ctx, database.CreateEmailVerificationTokenParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    user.ID,
+		Email:     user.Email,
+		ExpiresAt: time.Now().UTC().Add(emailVerificationExpiry),
+	})
+	if err != nil {
+		return err
+	}
+
+	return cfg.Mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Verify your Chirpy email address",
+		Body: fmt.Sprintf(
+			"Hi @%s,\n\nplease verify your email address by sending this token to POST /api/users/verify:\n\n%s\n\nThe token expires in %v.\n",
+			user.Handle, token, emailVerificationExpiry,
+		),
+	})
+}
+
+// sendPasswordResetEmail emails a new password reset token to user,
+// replacing any unused token sent before. Nothing is sent if the last unused
+// token is younger than passwordResetInterval.
+func (cfg *ApiConfig) sendPasswordResetEmail(ctx context.Context, user database.User) error {
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	created, err := cfg.Database.CreatePasswordResetToken(ctx, database.CreatePasswordResetTokenParams{
+		UserID:    user.ID,
+		SentAfter: now.Add(-passwordResetInterval),
+		TokenHash: auth.HashToken(token),
+		CreatedAt: now,
+		ExpiresAt: now.Add(passwordResetExpiry),
+	})
+	if err != nil {
+		return err
+	}
+	if created == 0 {
+		return nil
+	}
+
+	return cfg.Mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Reset your Chirpy password",
+		Body: fmt.Sprintf(
+			"Hi @%s,\n\nsomeone asked to reset your password. If that was you, send this token along with your new password to POST /api/password/reset:\n\n%s\n\nThe token expires in %v. If you did not ask for this, you can ignore this email.\n",
+			user.Handle, token, passwordResetExpiry,
+		),
+	})
+}
@@ -1,23 +1,80 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"sync/atomic"
+	"time"
 
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
+	"github.com/thewerther/webserver/internal/auth"
 	"github.com/thewerther/webserver/internal/database"
+	"github.com/thewerther/webserver/internal/mail"
+	"github.com/thewerther/webserver/internal/media"
+	"github.com/thewerther/webserver/internal/moderation"
+	"github.com/thewerther/webserver/internal/oidc"
 )
 
 type ApiConfig struct {
 	FileServerHits atomic.Int32
 	Database       *database.Queries
-	JWT_Secret     string
+	DB             *sql.DB
+	JWTKeys        *auth.KeySet
 	IsAdmin        bool
 	PolkaKey       string
+	Moderation     *moderation.Pipeline
+	// how long after deletion users and chirps can still be restored
+	RestoreWindow time.Duration
+	// how long after deletion users and chirps are purged from the database
+	DeletedRetention time.Duration
+	Media            media.Storage
+	MaxUploadBytes   int64
+	// signals the scheduler that a chirp has been scheduled
+	SchedulerWake chan struct{}
+	Mailer        mail.Mailer
+	// blocks users from chirping until they verified their email
+	RequireVerifiedEmail bool
+	PasswordHasher       auth.PasswordHasher
+	PasswordPolicy       *auth.PasswordPolicy
+	// revoked access tokens, kept in sync with the database
+	Revocations *auth.RevocationList
+	// nil if login with OpenID Connect is not set up
+	OIDC *oidc.Provider
+}
+
+// durationFromEnv parses an optional duration like "72h" from the environment.
+func durationFromEnv(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("%s is not a valid duration: %s", key, err)
+	}
+
+	return duration
+}
+
+// int64FromEnv parses an optional positive integer from the environment.
+func int64FromEnv(key string, fallback int64) int64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	number, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || number < 1 {
+		log.Fatalf("%s has to be a positive integer", key)
+	}
+
+	return number
 }
 
 func main() {
@@ -49,33 +106,157 @@ func main() {
 		log.Fatal("POLKA_KEY is not set in .env")
 	}
 
+	moderationPipeline, err := loadModerationPipeline(dbQueries)
+	if err != nil {
+		log.Fatalf("Error loading moderation filters: %s", err)
+	}
+
+	restoreWindow := durationFromEnv("RESTORE_WINDOW", 7*24*time.Hour)
+	deletedRetention := durationFromEnv("DELETED_RETENTION", 30*24*time.Hour)
+	if deletedRetention < restoreWindow {
+		log.Fatal("DELETED_RETENTION has to be at least as long as RESTORE_WINDOW")
+	}
+
+	mediaDir := os.Getenv("MEDIA_DIR")
+	if mediaDir == "" {
+		mediaDir = "media"
+	}
+	mediaStorage, err := media.NewLocalStorage(mediaDir)
+	if err != nil {
+		log.Fatalf("Error opening media storage: %s", err)
+	}
+
+	mailer, err := loadMailer()
+	if err != nil {
+		log.Fatalf("Error setting up mailer: %s", err)
+	}
+
+	jwtKeys, err := loadJWTKeys()
+	if err != nil {
+		log.Fatalf("Error loading JWT keys: %s", err)
+	}
+
+	passwordHasher, err := loadPasswordHasher()
+	if err != nil {
+		log.Fatalf("Error loading password hasher: %s", err)
+	}
+
+	passwordPolicy, err := loadPasswordPolicy()
+	if err != nil {
+		log.Fatalf("Error loading password policy: %s", err)
+	}
+
+	oidcProvider, fakeIdP, err := loadOIDCProvider("http://localhost:"+port, isAdmin == "dev")
+	if err != nil {
+		log.Fatalf("Error setting up OpenID Connect: %s", err)
+	}
+
 	apiCfg := &ApiConfig{
-		FileServerHits: atomic.Int32{},
-		Database:       dbQueries,
-		JWT_Secret:     os.Getenv("JWT_SECRET"),
-		IsAdmin:        isAdmin == "dev",
-		PolkaKey:       polkaKey,
+		FileServerHits:   atomic.Int32{},
+		Database:         dbQueries,
+		DB:               dbConn,
+		JWTKeys:          jwtKeys,
+		IsAdmin:          isAdmin == "dev",
+		PolkaKey:         polkaKey,
+		Moderation:       moderationPipeline,
+		RestoreWindow:    restoreWindow,
+		DeletedRetention: deletedRetention,
+		Media:            mediaStorage,
+		MaxUploadBytes:   int64FromEnv("MEDIA_MAX_BYTES", 5<<20),
+		SchedulerWake:    make(chan struct{}, 1),
+		Mailer:           mailer,
+		// opt-in so existing deployments keep working without a mail server
+		RequireVerifiedEmail: os.Getenv("REQUIRE_EMAIL_VERIFICATION") == "true",
+		PasswordHasher:       passwordHasher,
+		PasswordPolicy:       passwordPolicy,
+		Revocations:          auth.NewRevocationList(),
+		OIDC:                 oidcProvider,
+	}
+
+	revocationsSyncedAt, err := apiCfg.syncRevocations(context.Background(), time.Time{})
+	if err != nil {
+		log.Fatalf("Error loading access token revocations: %s", err)
 	}
 
+	go apiCfg.runPurgeJob(context.Background(), time.Hour)
+	go apiCfg.runScheduler(context.Background())
+	go apiCfg.runRevocationSync(context.Background(), revocationsSyncedAt, revocationSyncInterval)
+
 	serveMux := http.NewServeMux()
 	fileServerHandler := http.StripPrefix("/app", http.FileServer(http.Dir(rootPath)))
 	serveMux.Handle("/app/", apiCfg.middlewareMetricsInc(fileServerHandler))
 
 	serveMux.HandleFunc("GET /api/healthz", serveHealthz)
+	serveMux.HandleFunc("GET /.well-known/jwks.json", apiCfg.serveJWKS)
 
 	serveMux.HandleFunc("POST /api/chirps", apiCfg.createChirp)
 	serveMux.HandleFunc("GET /api/chirps", apiCfg.getChirps)
+	serveMux.HandleFunc("GET /api/chirps/search", apiCfg.searchChirps)
+	serveMux.HandleFunc("GET /api/chirps/scheduled", apiCfg.getScheduledChirps)
+	serveMux.HandleFunc("DELETE /api/chirps/{chirpID}/schedule", apiCfg.cancelScheduledChirp)
 	serveMux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.getChirpByID)
 	serveMux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.deleteChirpByID)
+	serveMux.HandleFunc("PATCH /api/chirps/{chirpID}", apiCfg.updateChirp)
+	serveMux.HandleFunc("GET /api/chirps/{chirpID}/revisions", apiCfg.getChirpRevisions)
+	serveMux.HandleFunc("GET /api/chirps/{chirpID}/replies", apiCfg.getReplies)
+	serveMux.HandleFunc("POST /api/chirps/{chirpID}/restore", apiCfg.restoreChirp)
+	serveMux.HandleFunc("POST /api/chirps/{chirpID}/report", apiCfg.reportChirp)
+	serveMux.HandleFunc("POST /api/chirps/{chirpID}/rechirp", apiCfg.rechirpChirp)
+	serveMux.HandleFunc("DELETE /api/chirps/{chirpID}/rechirp", apiCfg.undoRechirp)
+	serveMux.HandleFunc("POST /api/chirps/{chirpID}/quote", apiCfg.quoteChirp)
+	serveMux.HandleFunc("POST /api/chirps/{chirpID}/like", apiCfg.likeChirp)
+	serveMux.HandleFunc("DELETE /api/chirps/{chirpID}/like", apiCfg.unlikeChirp)
 
 	serveMux.HandleFunc("POST /api/users", apiCfg.createUser)
   serveMux.HandleFunc("PUT /api/users", apiCfg.updateUser)
+	serveMux.HandleFunc("DELETE /api/users", apiCfg.deleteUser)
+	serveMux.HandleFunc("PATCH /api/users", apiCfg.updateProfile)
+	for pattern, handler := range apiCfg.fixedUserRoutes() {
+		serveMux.HandleFunc(pattern, handler)
+	}
+	serveMux.HandleFunc("GET /api/users/{handle}", apiCfg.getProfile)
+	serveMux.HandleFunc("POST /api/users/{userID}/follow", apiCfg.followUser)
+	serveMux.HandleFunc("DELETE /api/users/{userID}/follow", apiCfg.unfollowUser)
+	serveMux.HandleFunc("GET /api/users/{userID}/followers", apiCfg.getFollowers)
+	serveMux.HandleFunc("GET /api/users/{userID}/following", apiCfg.getFollowing)
+	serveMux.HandleFunc("GET /api/timeline", apiCfg.getTimeline)
+	serveMux.HandleFunc("POST /api/media", apiCfg.uploadMedia)
+	serveMux.HandleFunc("GET /api/media/{mediaID}", apiCfg.getMedia)
+	serveMux.HandleFunc("GET /api/media/{mediaID}/thumbnail", apiCfg.getMediaThumbnail)
+	serveMux.HandleFunc("GET /api/hashtags/trending", apiCfg.getTrendingHashtags)
+	serveMux.HandleFunc("GET /api/hashtags/{tag}/chirps", apiCfg.getHashtagChirps)
 	serveMux.HandleFunc("POST /api/login", apiCfg.loginUser)
+	serveMux.HandleFunc("POST /api/login/2fa", apiCfg.loginTwoFactor)
+	if apiCfg.OIDC != nil {
+		serveMux.HandleFunc("GET /api/auth/oidc/login", apiCfg.startOIDCLogin)
+		serveMux.HandleFunc("GET /api/auth/oidc/callback", apiCfg.oidcCallback)
+	}
+	if fakeIdP != nil {
+		serveMux.Handle(fakeIdPPath+"/", fakeIdP)
+	}
+	serveMux.HandleFunc("POST /api/password/forgot", apiCfg.forgotPassword)
+	serveMux.HandleFunc("POST /api/password/reset", apiCfg.resetPassword)
 	serveMux.HandleFunc("POST /api/refresh", apiCfg.refreshToken)
 	serveMux.HandleFunc("POST /api/revoke", apiCfg.revokeRefreshToken)
+	serveMux.HandleFunc("GET /api/sessions", apiCfg.getSessions)
+	serveMux.HandleFunc("DELETE /api/sessions/{sessionID}", apiCfg.revokeSession)
+	serveMux.HandleFunc("POST /api/sessions/revoke-all", apiCfg.revokeAllSessions)
+	serveMux.HandleFunc("POST /api/logout", apiCfg.logout)
+	serveMux.HandleFunc("POST /api/logout/everywhere", apiCfg.revokeAllSessions)
+	serveMux.HandleFunc("POST /api/tokens", apiCfg.createPersonalAccessToken)
+	serveMux.HandleFunc("GET /api/tokens", apiCfg.getPersonalAccessTokens)
+	serveMux.HandleFunc("DELETE /api/tokens/{tokenID}", apiCfg.deletePersonalAccessToken)
 
 	serveMux.HandleFunc("GET /admin/metrics", apiCfg.serveAdminMetrics)
 	serveMux.HandleFunc("POST /admin/reset", apiCfg.resetServer)
+	serveMux.Handle("POST /admin/users/{userID}/restore", apiCfg.middlewareRequireRole(apiCfg.adminRestoreUser, roleAdmin))
+	serveMux.Handle("PUT /admin/users/{userID}/role", apiCfg.middlewareRequireRole(apiCfg.grantRole, roleAdmin))
+	serveMux.Handle("DELETE /admin/users/{userID}/role", apiCfg.middlewareRequireRole(apiCfg.revokeRole, roleAdmin))
+
+	serveMux.Handle("GET /admin/moderation/reports", apiCfg.middlewareRequireRole(apiCfg.getModerationQueue, roleModerator, roleAdmin))
+	serveMux.Handle("POST /admin/moderation/chirps/{chirpID}/approve", apiCfg.middlewareRequireRole(apiCfg.approveChirp, roleModerator, roleAdmin))
+	serveMux.Handle("POST /admin/moderation/chirps/{chirpID}/hide", apiCfg.middlewareRequireRole(apiCfg.hideChirp, roleModerator, roleAdmin))
+	serveMux.Handle("DELETE /admin/moderation/chirps/{chirpID}", apiCfg.middlewareRequireRole(apiCfg.deleteReportedChirp, roleModerator, roleAdmin))
 
 	serveMux.HandleFunc("POST /api/polka/webhooks", apiCfg.paymentHandler)
 
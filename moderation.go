@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/thewerther/webserver/internal/database"
+	"github.com/thewerther/webserver/internal/moderation"
+)
+
+// loadModerationPipeline builds the pipeline described by the file in
+// MODERATION_CONFIG, or the default word list if it is not set. Word lists
+// stored in the database are read once at startup.
+func loadModerationPipeline(dbQueries *database.Queries) (*moderation.Pipeline, error) {
+	config := moderation.DefaultConfig()
+
+	configPath := os.Getenv("MODERATION_CONFIG")
+	if configPath != "" {
+		var err error
+		config, err = moderation.LoadConfig(configPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return moderation.NewPipelineFromConfig(config, func() ([]string, error) {
+		return dbQueries.GetModerationWords(context.Background())
+	})
+}
+
+// flagChirpForReview records why a chirp was flagged, failing to do so must
+// not fail the request since the chirp has already been stored.
+func (cfg *ApiConfig) flagChirpForReview(ctx context.Context, chirpID uuid.UUID, result moderation.Result) {
+	if !result.Flagged {
+		return
+	}
+
+	err := cfg.Database.CreateModerationFlag(ctx, database.CreateModerationFlagParams{
+		ChirpID: chirpID,
+		Reason:  "flagged by " + strings.Join(result.Reasons, ", "),
+	})
+	if err != nil {
+		log.Printf("Error flagging chirp %v for review: %v", chirpID, err)
+	}
+}
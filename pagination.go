@@ -0,0 +1,175 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// pageCursor points at the last row of a page. Rows are ordered by
+// (created_at, id) so the id breaks ties between rows created at the same time.
+type pageCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// encodeCursor returns an opaque token that clients pass back as `cursor`.
+func encodeCursor(c pageCursor) string {
+	raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(token string) (pageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return pageCursor{}, errors.New("Malformed cursor")
+	}
+
+	createdAtStr, idStr, found := strings.Cut(string(raw), "|")
+	if !found {
+		return pageCursor{}, errors.New("Malformed cursor")
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, createdAtStr)
+	if err != nil {
+		return pageCursor{}, errors.New("Malformed cursor timestamp")
+	}
+
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return pageCursor{}, errors.New("Malformed cursor id")
+	}
+
+	return pageCursor{CreatedAt: createdAt, ID: id}, nil
+}
+
+// rankCursor points at the last row of a page of search results ordered by
+// (rank, id).
+type rankCursor struct {
+	Rank float32
+	ID   uuid.UUID
+}
+
+// encodeRankCursor formats the rank with the fewest digits that parse back to
+// the same float32, rows are compared against it exactly.
+func encodeRankCursor(c rankCursor) string {
+	raw := strconv.FormatFloat(float64(c.Rank), 'g', -1, 32) + "|" + c.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeRankCursor(token string) (rankCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return rankCursor{}, errors.New("Malformed cursor")
+	}
+
+	rankStr, idStr, found := strings.Cut(string(raw), "|")
+	if !found {
+		return rankCursor{}, errors.New("Malformed cursor")
+	}
+
+	rank, err := strconv.ParseFloat(rankStr, 32)
+	if err != nil {
+		return rankCursor{}, errors.New("Malformed cursor rank")
+	}
+
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return rankCursor{}, errors.New("Malformed cursor id")
+	}
+
+	return rankCursor{Rank: float32(rank), ID: id}, nil
+}
+
+// parsePageLimit reads `limit` from the query string, falling back to
+// defaultPageLimit and capping at maxPageLimit.
+func parsePageLimit(query url.Values) (int32, error) {
+	limitParam := query.Get("limit")
+	if limitParam == "" {
+		return defaultPageLimit, nil
+	}
+
+	limit, err := strconv.Atoi(limitParam)
+	if err != nil || limit < 1 {
+		return 0, errors.New("limit has to be a positive integer")
+	}
+
+	if limit > maxPageLimit {
+		limit = maxPageLimit
+	}
+
+	return int32(limit), nil
+}
+
+// pageParams holds `limit` and `cursor` of paginated endpoints, already
+// converted to the nullable types used by the queries.
+type pageParams struct {
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	Limit           int32
+}
+
+func parsePageParams(query url.Values) (pageParams, error) {
+	params := pageParams{}
+
+	limit, err := parsePageLimit(query)
+	if err != nil {
+		return pageParams{}, err
+	}
+	params.Limit = limit
+
+	cursorParam := query.Get("cursor")
+	if cursorParam != "" {
+		cursor, err := decodeCursor(cursorParam)
+		if err != nil {
+			return pageParams{}, err
+		}
+		params.CursorCreatedAt = sql.NullTime{Time: cursor.CreatedAt, Valid: true}
+		params.CursorID = uuid.NullUUID{UUID: cursor.ID, Valid: true}
+	}
+
+	return params, nil
+}
+
+// chirpListParams holds the filters shared by all endpoints returning lists
+// of chirps.
+type chirpListParams struct {
+	pageParams
+	AuthorID   uuid.NullUUID
+	Descending bool
+}
+
+// parseChirpListParams reads `author_id`, `sort`, `limit` and `cursor` from
+// the query string.
+func parseChirpListParams(query url.Values) (chirpListParams, error) {
+	page, err := parsePageParams(query)
+	if err != nil {
+		return chirpListParams{}, err
+	}
+	params := chirpListParams{pageParams: page}
+
+	authorIDParam := query.Get("author_id")
+	if authorIDParam != "" {
+		id, err := uuid.Parse(authorIDParam)
+		if err != nil {
+			return chirpListParams{}, errors.New("author_id has to be a valid uuid")
+		}
+		params.AuthorID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	// default is "asc"
+	params.Descending = query.Get("sort") == "desc"
+
+	return params, nil
+}
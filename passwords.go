@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+
+	"github.com/thewerther/webserver/internal/auth"
+	"github.com/thewerther/webserver/internal/database"
+)
+
+// loadPasswordHasher configures argon2id from ARGON2_MEMORY_KIB,
+// ARGON2_ITERATIONS and ARGON2_PARALLELISM. Changing them makes existing
+// hashes get rehashed on the next login.
+func loadPasswordHasher() (auth.PasswordHasher, error) {
+	params := auth.DefaultArgon2idParams()
+	memory := int64FromEnv("ARGON2_MEMORY_KIB", int64(params.Memory))
+	iterations := int64FromEnv("ARGON2_ITERATIONS", int64(params.Iterations))
+	parallelism := int64FromEnv("ARGON2_PARALLELISM", int64(params.Parallelism))
+
+	// argon2 panics or silently wraps around outside of these
+	if parallelism > math.MaxUint8 {
+		return nil, fmt.Errorf("ARGON2_PARALLELISM can be at most %d", math.MaxUint8)
+	}
+	if iterations > math.MaxUint32 {
+		return nil, fmt.Errorf("ARGON2_ITERATIONS can be at most %d", uint32(math.MaxUint32))
+	}
+	if memory > math.MaxUint32 {
+		return nil, fmt.Errorf("ARGON2_MEMORY_KIB can be at most %d", uint32(math.MaxUint32))
+	}
+	if memory < 8*parallelism {
+		return nil, errors.New("ARGON2_MEMORY_KIB has to be at least 8 times ARGON2_PARALLELISM")
+	}
+
+	params.Memory = uint32(memory)
+	params.Iterations = uint32(iterations)
+	params.Parallelism = uint8(parallelism)
+
+	return auth.NewArgon2idHasher(params), nil
+}
+
+// loadPasswordPolicy reads the length limits from PASSWORD_MIN_LENGTH and
+// PASSWORD_MAX_LENGTH and the breached passwords from BREACHED_PASSWORDS_FILE.
+func loadPasswordPolicy() (*auth.PasswordPolicy, error) {
+	policy := auth.DefaultPasswordPolicy()
+	policy.MinLength = int(int64FromEnv("PASSWORD_MIN_LENGTH", int64(policy.MinLength)))
+	policy.MaxLength = int(int64FromEnv("PASSWORD_MAX_LENGTH", int64(policy.MaxLength)))
+	if policy.MinLength < 1 {
+		return nil, errors.New("PASSWORD_MIN_LENGTH has to be at least 1")
+	}
+	if policy.MaxLength < policy.MinLength {
+		return nil, errors.New("PASSWORD_MAX_LENGTH has to be at least PASSWORD_MIN_LENGTH")
+	}
+
+	breachedFile := os.Getenv("BREACHED_PASSWORDS_FILE")
+	if breachedFile != "" {
+		err := policy.LoadBreachedPasswords(breachedFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return policy, nil
+}
+
+// hashNewPassword checks a password against the policy before hashing it. It
+// writes the error response itself and returns false if the password can not
+// be used.
+func (cfg *ApiConfig) hashNewPassword(w http.ResponseWriter, password, email string) (string, bool) {
+	err := cfg.PasswordPolicy.Check(password, email)
+	policyErr := &auth.PolicyError{}
+	if errors.As(err, &policyErr) {
+		type policyErrorResponse struct {
+			Error      string                 `json:"error"`
+			Violations []auth.PolicyViolation `json:"violations"`
+		}
+		respondWithJSON(w, http.StatusUnprocessableEntity, policyErrorResponse{
+			Error:      "Password does not meet the requirements",
+			Violations: policyErr.Violations,
+		})
+		return "", false
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error checking password", err)
+		return "", false
+	}
+
+	hashedPassword, err := cfg.PasswordHasher.Hash(password)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error hashing password", err)
+		return "", false
+	}
+
+	return hashedPassword, true
+}
+
+// rehashPassword replaces a hash made with bcrypt or outdated argon2id
+// parameters after the user logged in with the password. The login works
+// either way, so errors are only logged.
+func (cfg *ApiConfig) rehashPassword(req *http.Request, user database.User, password string) {
+	hashedPassword, err := cfg.PasswordHasher.Hash(password)
+	if err != nil {
+		log.Printf("Error rehashing password of user %v: %v", user.ID, err)
+		return
+	}
+
+	err = cfg.Database.SetUserPasswordHash(req.Context(), database.SetUserPasswordHashParams{
+		ID:             user.ID,
+		OldHash:        user.HashedPassword,
+		HashedPassword: hashedPassword,
+	})
+	if err != nil {
+		log.Printf("Error storing rehashed password of user %v: %v", user.ID, err)
+	}
+}
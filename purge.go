@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// runPurgeJob hard-deletes users and chirps whose tombstone is older than
+// DeletedRetention and orphaned media, once right away and then every
+// interval until ctx is done.
+func (cfg *ApiConfig) runPurgeJob(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		cfg.purgeDeleted(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (cfg *ApiConfig) purgeDeleted(ctx context.Context) {
+	deletedBefore := time.Now().UTC().Add(-cfg.DeletedRetention)
+
+	// purging a user also removes their chirps through ON DELETE CASCADE
+	numUsers, err := cfg.Database.PurgeDeletedUsers(ctx, deletedBefore)
+	if err != nil {
+		log.Printf("Error purging deleted users: %v", err)
+		return
+	}
+
+	numChirps, err := cfg.Database.PurgeDeletedChirps(ctx, deletedBefore)
+	if err != nil {
+		log.Printf("Error purging deleted chirps: %v", err)
+		return
+	}
+
+	if numUsers > 0 || numChirps > 0 {
+		log.Printf("Purged %v deleted users and %v deleted chirps", numUsers, numChirps)
+	}
+
+	cfg.purgeOrphanedMedia(ctx)
+	cfg.purgeAccessTokenRevocations(ctx)
+}
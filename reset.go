@@ -15,11 +15,14 @@ func (cfg *ApiConfig) resetServer(w http.ResponseWriter, req *http.Request) {
 	cfg.FileServerHits.Store(0)
 
 	// this will also delete all chirps due to database constraints requiring
-	// an existing user in the users db
+	// an existing user in the users db, unlike deleting a single user this is
+	// not a soft delete
 	numUsersDel, err := cfg.Database.DeleteUsers(req.Context())
 	if err != nil {
 		fmt.Println(err)
 	}
+	// the media rows are kept without a user, this removes them and their files
+	cfg.purgeOrphanedMedia(req.Context())
   fmt.Printf("--------------------- Cleared database ---------------------\nNum of deleted Users: %v\n------------------------------------------------------------\n", numUsersDel)
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(fmt.Sprintf("Hits reset to 0\nDeleted %v users from database", numUsersDel)))
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/thewerther/webserver/internal/auth"
+	"github.com/thewerther/webserver/internal/database"
+)
+
+const (
+	// the longest expiry access tokens are issued with, revocations older
+	// than that can be forgotten
+	maxAccessTokenLifetime = time.Hour
+	// revocations of other instances sharing the database take at most this
+	// long to be picked up
+	revocationSyncInterval = 30 * time.Second
+	// rows are read again for this long in case they were committed after a
+	// sync with an earlier timestamp
+	revocationSyncOverlap = time.Minute
+)
+
+// runRevocationSync keeps Revocations up to date with the database every
+// interval until ctx is done, starting with the revocations changed since.
+func (cfg *ApiConfig) runRevocationSync(ctx context.Context, since time.Time, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		latest, err := cfg.syncRevocations(ctx, since.Add(-revocationSyncOverlap))
+		if err != nil {
+			log.Printf("Error syncing access token revocations: %v", err)
+			continue
+		}
+		since = latest
+		cfg.Revocations.Prune(time.Now(), maxAccessTokenLifetime)
+	}
+}
+
+// syncRevocations loads the revocations changed since the given time and
+// returns the latest change it saw.
+func (cfg *ApiConfig) syncRevocations(ctx context.Context, since time.Time) (time.Time, error) {
+	latest := since
+
+	tokens, err := cfg.Database.GetRevokedAccessTokensSince(ctx, database.GetRevokedAccessTokensSinceParams{
+		Since: since,
+		Now:   time.Now().UTC(),
+	})
+	if err != nil {
+		return since, err
+	}
+	for _, token := range tokens {
+		cfg.Revocations.RevokeToken(token.Jti, token.ExpiresAt)
+		if token.RevokedAt.After(latest) {
+			latest = token.RevokedAt
+		}
+	}
+
+	cutoffs, err := cfg.Database.GetAccessTokenCutoffsSince(ctx, since)
+	if err != nil {
+		return since, err
+	}
+	for _, cutoff := range cutoffs {
+		cfg.Revocations.RevokeUserTokens(cutoff.UserID, cutoff.RevokedBefore)
+		if cutoff.UpdatedAt.After(latest) {
+			latest = cutoff.UpdatedAt
+		}
+	}
+
+	return latest, nil
+}
+
+// revokeAccessToken revokes a single access token until it expires.
+func (cfg *ApiConfig) revokeAccessToken(ctx context.Context, claims *auth.Claims) error {
+	userID, err := claims.UserID()
+	if err != nil {
+		return err
+	}
+
+	err = cfg.Database.RevokeAccessToken(ctx, database.RevokeAccessTokenParams{
+		Jti:       claims.ID,
+		UserID:    userID,
+		RevokedAt: time.Now().UTC(),
+		ExpiresAt: claims.ExpiresAt.Time.UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	cfg.Revocations.RevokeToken(claims.ID, claims.ExpiresAt.Time)
+	return nil
+}
+
+// logOutEverywhere revokes all refresh tokens of a user and all access tokens
+// issued until now.
+func (cfg *ApiConfig) logOutEverywhere(ctx context.Context, userID uuid.UUID) error {
+	_, err := cfg.Database.RevokeUserSessions(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	return cfg.revokeUserAccessTokens(ctx, userID)
+}
+
+// revokeUserAccessTokens revokes all access tokens of a user issued until now.
+func (cfg *ApiConfig) revokeUserAccessTokens(ctx context.Context, userID uuid.UUID) error {
+	// the cutoff is compared with the iat of tokens, so it has to come from
+	// the same clock that issues them and not from the database
+	cutoff, err := cfg.Database.RevokeUserAccessTokens(ctx, database.RevokeUserAccessTokensParams{
+		UserID:        userID,
+		RevokedBefore: time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	cfg.Revocations.RevokeUserTokens(cutoff.UserID, cutoff.RevokedBefore)
+	return nil
+}
+
+func (cfg *ApiConfig) purgeAccessTokenRevocations(ctx context.Context) {
+	now := time.Now().UTC()
+	err := cfg.Database.PurgeAccessTokenRevocations(ctx, database.PurgeAccessTokenRevocationsParams{
+		CutoffsBefore: now.Add(-maxAccessTokenLifetime),
+		Now:           now,
+	})
+	if err != nil {
+		log.Printf("Error purging access token revocations: %v", err)
+	}
+}
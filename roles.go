@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/thewerther/webserver/internal/database"
+)
+
+const (
+	roleUser      = "user"
+	roleModerator = "moderator"
+	roleAdmin     = "admin"
+)
+
+var validRoles = []string{roleUser, roleModerator, roleAdmin}
+
+type RoleRequest struct {
+	Role string `json:"role"`
+}
+
+type contextKey string
+
+const userContextKey contextKey = "user"
+
+// middlewareRequireRole only lets requests through whose access token carries
+// one of roles. The role is checked against the database as well, so revoking
+// a role takes effect before the access token expires. The authenticated user
+// is passed on to next, see userFromContext.
+func (cfg *ApiConfig) middlewareRequireRole(next http.HandlerFunc, roles ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		claims, err := parseAccessToken(req, cfg)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+			return
+		}
+
+		if !slices.Contains(roles, claims.Role) {
+			respondWithError(w, http.StatusForbidden, "Insufficient role", errors.New("Role "+claims.Role+" is not allowed"))
+			return
+		}
+
+		userExists, err := authenticateSession(req, cfg)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+			return
+		}
+
+		if !slices.Contains(roles, userExists.Role) {
+			respondWithError(w, http.StatusForbidden, "Insufficient role", errors.New("Role has been revoked"))
+			return
+		}
+
+		ctx := context.WithValue(req.Context(), userContextKey, userExists)
+		next.ServeHTTP(w, req.WithContext(ctx))
+	})
+}
+
+// userFromContext returns the user authenticated by middlewareRequireRole.
+func userFromContext(ctx context.Context) database.User {
+	userExists, _ := ctx.Value(userContextKey).(database.User)
+	return userExists
+}
+
+func (cfg *ApiConfig) grantRole(w http.ResponseWriter, req *http.Request) {
+	roleReq := RoleRequest{}
+	err := decodeRequestBody(&roleReq, req)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error decoding request", err)
+		return
+	}
+
+	if !slices.Contains(validRoles, roleReq.Role) {
+		respondWithError(w, http.StatusBadRequest, "Unknown role", errors.New("Unknown role "+roleReq.Role))
+		return
+	}
+
+	cfg.setUserRole(w, req, roleReq.Role)
+}
+
+func (cfg *ApiConfig) revokeRole(w http.ResponseWriter, req *http.Request) {
+	cfg.setUserRole(w, req, roleUser)
+}
+
+func (cfg *ApiConfig) setUserRole(w http.ResponseWriter, req *http.Request, role string) {
+	admin := userFromContext(req.Context())
+
+	id, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Error getting user id from request", err)
+		return
+	}
+
+	// admins step down through another admin, so nobody demotes themselves by accident
+	if id == admin.ID && role != roleAdmin {
+		respondWithError(w, http.StatusBadRequest, "Cannot revoke your own admin role", nil)
+		return
+	}
+
+	tx, err := cfg.DB.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating role of user", err)
+		return
+	}
+	defer tx.Rollback()
+	queries := cfg.Database.WithTx(tx)
+
+	admins, err := queries.LockAdmins(req.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating role of user", err)
+		return
+	}
+
+	if role != roleAdmin && len(admins) == 1 && admins[0] == id {
+		respondWithError(w, http.StatusConflict, "Cannot revoke the role of the last admin", nil)
+		return
+	}
+
+	updatedUser, err := queries.SetUserRole(req.Context(), database.SetUserRoleParams{
+		ID:   id,
+		Role: role,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Error updating role of user", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating role of user", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating role of user", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, userToResponse(updatedUser))
+}
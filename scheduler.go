@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+)
+
+// maxSchedulerSleep bounds how long the scheduler waits between checks, so
+// chirps scheduled by other instances sharing the database are not missed.
+const maxSchedulerSleep = time.Minute
+
+// runScheduler publishes pending chirps once they are due until ctx is done.
+// Pending chirps are read from the database, so chirps that became due while
+// the server was down are published right after it starts.
+func (cfg *ApiConfig) runScheduler(ctx context.Context) {
+	for {
+		cfg.publishDueChirps(ctx)
+
+		timer := time.NewTimer(nextPublishIn(cfg.nextPublishAt(ctx), time.Now().UTC()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-cfg.SchedulerWake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// wakeScheduler makes the scheduler look for the next due chirp again after
+// a chirp has been scheduled.
+func (cfg *ApiConfig) wakeScheduler() {
+	select {
+	case cfg.SchedulerWake <- struct{}{}:
+	default:
+	}
+}
+
+func (cfg *ApiConfig) publishDueChirps(ctx context.Context) {
+	published, err := cfg.Database.PublishDueChirps(ctx, time.Now().UTC())
+	if err != nil {
+		log.Printf("Error publishing scheduled chirps: %v", err)
+		return
+	}
+
+	if len(published) > 0 {
+		log.Printf("Published %v scheduled chirps", len(published))
+	}
+}
+
+// nextPublishAt returns when the next pending chirp is due, it is null if
+// there is none or it could not be queried.
+func (cfg *ApiConfig) nextPublishAt(ctx context.Context) sql.NullTime {
+	publishAt, err := cfg.Database.GetNextPublishAt(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return sql.NullTime{}
+	}
+	if err != nil {
+		log.Printf("Error querying next scheduled chirp: %v", err)
+		return sql.NullTime{}
+	}
+
+	return publishAt
+}
+
+// nextPublishIn returns how long the scheduler sleeps at now until the chirp
+// due at publishAt, it checks again after maxSchedulerSleep at the latest.
+func nextPublishIn(publishAt sql.NullTime, now time.Time) time.Duration {
+	if !publishAt.Valid {
+		return maxSchedulerSleep
+	}
+
+	wait := publishAt.Time.Sub(now)
+	if wait < 0 {
+		return 0
+	}
+
+	return min(wait, maxSchedulerSleep)
+}
@@ -0,0 +1,39 @@
+-- name: RevokeAccessToken :exec
+-- times are passed in from Go, they are compared with the iat and exp of
+-- tokens which do not depend on the time zone of the database
+INSERT INTO revoked_access_tokens (jti, user_id, revoked_at, expires_at)
+VALUES (
+  $1,
+  $2,
+  $3,
+  $4
+)
+ON CONFLICT (jti) DO NOTHING;
+
+-- name: RevokeUserAccessTokens :one
+INSERT INTO access_token_cutoffs (user_id, revoked_before, updated_at)
+VALUES (
+  $1,
+  $2,
+  $2
+)
+ON CONFLICT (user_id) DO UPDATE
+SET revoked_before = EXCLUDED.revoked_before, updated_at = EXCLUDED.updated_at
+RETURNING *;
+
+-- name: GetRevokedAccessTokensSince :many
+SELECT * FROM revoked_access_tokens
+WHERE revoked_at >= sqlc.arg('since') AND expires_at > sqlc.arg('now');
+
+-- name: GetAccessTokenCutoffsSince :many
+SELECT * FROM access_token_cutoffs
+WHERE updated_at >= $1;
+
+-- name: PurgeAccessTokenRevocations :exec
+-- cutoffs only matter while tokens issued before them can still be valid
+WITH removed_cutoffs AS (
+  DELETE FROM access_token_cutoffs
+  WHERE access_token_cutoffs.revoked_before < sqlc.arg('cutoffs_before')::timestamp
+)
+DELETE FROM revoked_access_tokens
+WHERE revoked_access_tokens.expires_at < sqlc.arg('now')::timestamp;
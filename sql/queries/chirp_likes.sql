@@ -0,0 +1,12 @@
+-- name: LikeChirp :execrows
+INSERT INTO chirp_likes (chirp_id, user_id, created_at)
+VALUES (
+  $1,
+  $2,
+  NOW()
+)
+ON CONFLICT DO NOTHING;
+
+-- name: UnlikeChirp :execrows
+DELETE FROM chirp_likes
+WHERE chirp_id = $1 AND user_id = $2;
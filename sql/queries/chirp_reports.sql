@@ -0,0 +1,27 @@
+-- name: CreateChirpReport :execrows
+INSERT INTO chirp_reports (id, chirp_id, reporter_id, reason, created_at)
+VALUES (
+  gen_random_uuid(),
+  $1::uuid,
+  $2,
+  $3,
+  NOW()
+)
+ON CONFLICT (chirp_id, reporter_id) WHERE resolved_at IS NULL DO NOTHING;
+
+-- name: GetOpenReportedChirps :many
+SELECT chirps.id, chirps.body, chirps.user_id, chirps.created_at, chirps.hidden_at,
+  COUNT(chirp_reports.id) AS report_count,
+  MIN(chirp_reports.created_at)::timestamp AS first_reported_at,
+  array_agg(chirp_reports.reason ORDER BY chirp_reports.created_at)::text[] AS reasons
+FROM chirp_reports
+INNER JOIN chirps ON chirps.id = chirp_reports.chirp_id
+WHERE chirp_reports.resolved_at IS NULL AND chirps.deleted_at IS NULL
+GROUP BY chirps.id
+ORDER BY report_count DESC, first_reported_at ASC
+LIMIT $1;
+
+-- name: ResolveChirpReports :execrows
+UPDATE chirp_reports
+SET resolved_at = NOW(), resolved_by = $2, resolution = $3
+WHERE chirp_id = $1::uuid AND resolved_at IS NULL;
@@ -0,0 +1,24 @@
+-- name: LockChirpForEdit :one
+-- concurrent edits wait for each other, so every replaced body is kept
+SELECT id FROM chirps
+WHERE id = $1 AND deleted_at IS NULL AND hidden_at IS NULL AND publish_at IS NULL
+FOR UPDATE;
+
+-- name: UpdateChirpBody :one
+-- keeps the replaced body as a revision, created_at of the revision is the
+-- time that body was written
+WITH revision AS (
+  INSERT INTO chirp_revisions (id, chirp_id, body, created_at, replaced_at)
+  SELECT gen_random_uuid(), id, body, updated_at, NOW()
+  FROM chirps
+  WHERE id = $1 AND deleted_at IS NULL AND hidden_at IS NULL AND publish_at IS NULL
+)
+UPDATE chirps
+SET body = $2, updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL AND hidden_at IS NULL AND publish_at IS NULL
+RETURNING *;
+
+-- name: GetChirpRevisions :many
+SELECT * FROM chirp_revisions
+WHERE chirp_id = $1
+ORDER BY replaced_at ASC;
@@ -1,27 +1,146 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, body, user_id, created_at, updated_at)
+-- created_at comes from the server like publish_at, so scheduled chirps are
+-- dated with the same clock as the others
+INSERT INTO chirps (id, body, user_id, reply_to_id, quote_of_id, publish_at, created_at, updated_at)
 VALUES (
   gen_random_uuid(),
   $1,
   $2,
-  NOW(),
-  NOW()
+  $3,
+  $4,
+  $5,
+  $6,
+  $6
 )
 RETURNING *;
 
 -- name: GetChirps :many
 SELECT * FROM chirps
+WHERE deleted_at IS NULL AND hidden_at IS NULL AND publish_at IS NULL
 ORDER BY created_at ASC;
 
 -- name: GetChirpByID :one
 SELECT * FROM chirps
-WHERE id = $1;
+WHERE id = $1 AND deleted_at IS NULL AND hidden_at IS NULL AND publish_at IS NULL;
 
--- name: DeleteChirpByID :exec
-DELETE from chirps
-WHERE id = $1;
+-- name: SoftDeleteChirpByID :exec
+UPDATE chirps
+SET deleted_at = sqlc.arg('deleted_at')::timestamp
+WHERE id = sqlc.arg('id') AND deleted_at IS NULL;
+
+-- name: GetDeletedChirpByID :one
+SELECT * FROM chirps
+WHERE id = $1 AND deleted_at IS NOT NULL;
+
+-- name: RestoreChirpByID :one
+UPDATE chirps
+SET deleted_at = NULL
+WHERE id = $1 AND deleted_at >= sqlc.arg('deleted_after')::timestamp
+RETURNING *;
+
+-- name: PurgeDeletedChirps :execrows
+DELETE FROM chirps
+WHERE deleted_at < sqlc.arg('deleted_before')::timestamp;
 
 -- name: GetChirpsByUserID :many
 SELECT * FROM chirps
-WHERE user_id = $1
+WHERE user_id = $1 AND deleted_at IS NULL AND hidden_at IS NULL AND publish_at IS NULL
 ORDER BY created_at ASC;
+
+-- name: GetChirpsPageAsc :many
+SELECT * FROM chirps
+WHERE deleted_at IS NULL AND hidden_at IS NULL AND publish_at IS NULL
+  AND (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id')::uuid)
+  -- plain rechirps only show up on the profile of whoever rechirped
+  AND (sqlc.narg('author_id')::uuid IS NOT NULL OR rechirp_of_id IS NULL)
+  AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+  )
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('page_limit');
+
+-- name: GetChirpsPageDesc :many
+SELECT * FROM chirps
+WHERE deleted_at IS NULL AND hidden_at IS NULL AND publish_at IS NULL
+  AND (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id')::uuid)
+  -- plain rechirps only show up on the profile of whoever rechirped
+  AND (sqlc.narg('author_id')::uuid IS NOT NULL OR rechirp_of_id IS NULL)
+  AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+  )
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: HideChirpByID :execrows
+UPDATE chirps
+SET hidden_at = sqlc.arg('hidden_at')::timestamp
+WHERE id = sqlc.arg('id') AND deleted_at IS NULL;
+
+-- name: UnhideChirpByID :execrows
+UPDATE chirps
+SET hidden_at = NULL
+WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: DeleteChirpByID :execrows
+DELETE FROM chirps
+WHERE id = $1;
+
+-- name: GetChirpStats :many
+-- counts for a whole page of chirps at once, liked_by_viewer is false if
+-- viewer_id is NULL
+SELECT chirps.id AS chirp_id,
+  (SELECT COUNT(*) FROM chirp_likes WHERE chirp_likes.chirp_id = chirps.id) AS like_count,
+  EXISTS(
+    SELECT 1 FROM chirp_likes
+    WHERE chirp_likes.chirp_id = chirps.id AND chirp_likes.user_id = sqlc.narg('viewer_id')::uuid
+  ) AS liked_by_viewer,
+  (
+    SELECT COUNT(*) FROM chirps AS replies
+    WHERE replies.reply_to_id = chirps.id AND replies.deleted_at IS NULL AND replies.hidden_at IS NULL AND replies.publish_at IS NULL
+  ) AS reply_count,
+  users.handle AS author_handle
+FROM chirps
+INNER JOIN users ON users.id = chirps.user_id
+WHERE chirps.id = ANY(sqlc.arg('chirp_ids')::uuid[]);
+
+-- name: ChirpWasPublished :one
+-- deleted and hidden chirps count as well, their replies stay visible
+SELECT EXISTS(
+  SELECT 1 FROM chirps
+  WHERE id = $1 AND publish_at IS NULL
+);
+
+-- name: GetRepliesPage :many
+SELECT * FROM chirps
+WHERE reply_to_id = sqlc.arg('reply_to_id')
+  AND deleted_at IS NULL AND hidden_at IS NULL AND publish_at IS NULL
+  AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+  )
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('page_limit');
+
+-- name: CreateRechirp :one
+INSERT INTO chirps (id, body, user_id, rechirp_of_id, created_at, updated_at)
+VALUES (
+  gen_random_uuid(),
+  '',
+  $1,
+  $2,
+  $3,
+  $3
+)
+ON CONFLICT (user_id, rechirp_of_id) WHERE rechirp_of_id IS NOT NULL AND deleted_at IS NULL DO NOTHING
+RETURNING *;
+
+-- name: DeleteRechirp :execrows
+-- plain rechirps are not restorable, so undoing one removes it for good
+DELETE FROM chirps
+WHERE user_id = $1 AND rechirp_of_id = $2 AND deleted_at IS NULL;
+
+-- name: GetChirpsByIDs :many
+SELECT * FROM chirps
+WHERE id = ANY(sqlc.arg('ids')::uuid[]) AND deleted_at IS NULL AND hidden_at IS NULL AND publish_at IS NULL;
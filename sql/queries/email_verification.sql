@@ -0,0 +1,28 @@
+-- name: CreateEmailVerificationToken :exec
+-- older tokens of the user are replaced
+WITH removed AS (
+  DELETE FROM email_verification_tokens
+  WHERE email_verification_tokens.user_id = $2
+)
+INSERT INTO email_verification_tokens (token_hash, user_id, email, created_at, expires_at)
+VALUES (
+  $1,
+  $2,
+  $3,
+  NOW(),
+  $4
+);
+
+-- name: VerifyEmail :one
+-- consumes the token and marks the email it was sent to as verified
+WITH used_token AS (
+  DELETE FROM email_verification_tokens
+  WHERE token_hash = sqlc.arg('token_hash')
+  RETURNING user_id, email, expires_at
+)
+UPDATE users
+SET email_verified_at = sqlc.arg('now')::timestamp, updated_at = NOW()
+FROM used_token
+WHERE users.id = used_token.user_id AND users.email = used_token.email
+  AND used_token.expires_at > sqlc.arg('now')::timestamp AND users.deleted_at IS NULL
+RETURNING users.*;
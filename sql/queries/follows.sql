@@ -0,0 +1,50 @@
+-- name: FollowUser :execrows
+INSERT INTO follows (follower_id, followee_id, created_at)
+VALUES (
+  $1,
+  $2,
+  NOW()
+)
+ON CONFLICT DO NOTHING;
+
+-- name: UnfollowUser :execrows
+DELETE FROM follows
+WHERE follower_id = $1 AND followee_id = $2;
+
+-- name: GetFollowers :many
+SELECT follows.follower_id AS user_id, follows.created_at
+FROM follows
+INNER JOIN users ON users.id = follows.follower_id
+WHERE follows.followee_id = sqlc.arg('user_id')
+  AND users.deleted_at IS NULL
+  AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (follows.created_at, follows.follower_id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+  )
+ORDER BY follows.created_at DESC, follows.follower_id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: GetFollowing :many
+SELECT follows.followee_id AS user_id, follows.created_at
+FROM follows
+INNER JOIN users ON users.id = follows.followee_id
+WHERE follows.follower_id = sqlc.arg('user_id')
+  AND users.deleted_at IS NULL
+  AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (follows.created_at, follows.followee_id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+  )
+ORDER BY follows.created_at DESC, follows.followee_id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: GetTimelinePage :many
+SELECT chirps.* FROM chirps
+INNER JOIN follows ON follows.followee_id = chirps.user_id
+WHERE follows.follower_id = sqlc.arg('follower_id')
+  AND chirps.deleted_at IS NULL AND chirps.hidden_at IS NULL AND chirps.publish_at IS NULL
+  AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+  )
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg('page_limit');
@@ -0,0 +1,44 @@
+-- name: SetChirpHashtags :exec
+-- replaces the hashtags of a chirp, tags that have never been used before are
+-- created on the fly
+WITH removed AS (
+  DELETE FROM chirp_hashtags
+  WHERE chirp_hashtags.chirp_id = sqlc.arg('chirp_id')
+), all_hashtags AS (
+  -- the no-op update makes RETURNING include tags that already exist, even
+  -- if another chirp created them concurrently
+  INSERT INTO hashtags (id, tag, created_at)
+  SELECT gen_random_uuid(), tags.tag, NOW()
+  FROM (SELECT DISTINCT unnest(sqlc.arg('tags')::text[]) AS tag) AS tags
+  ON CONFLICT (tag) DO UPDATE SET tag = EXCLUDED.tag
+  RETURNING hashtags.id
+)
+INSERT INTO chirp_hashtags (chirp_id, hashtag_id)
+SELECT sqlc.arg('chirp_id'), all_hashtags.id
+FROM all_hashtags;
+
+-- name: GetHashtagChirpsPage :many
+SELECT chirps.* FROM chirps
+INNER JOIN chirp_hashtags ON chirp_hashtags.chirp_id = chirps.id
+INNER JOIN hashtags ON hashtags.id = chirp_hashtags.hashtag_id
+WHERE hashtags.tag = sqlc.arg('tag')
+  AND chirps.deleted_at IS NULL AND chirps.hidden_at IS NULL AND chirps.publish_at IS NULL
+  AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+  )
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: GetTrendingHashtags :many
+-- tags used by the most chirps written since created_after, ties go to the
+-- tag that was used most recently
+SELECT hashtags.tag, COUNT(*) AS chirp_count, MAX(chirps.created_at)::timestamp AS last_used_at
+FROM hashtags
+INNER JOIN chirp_hashtags ON chirp_hashtags.hashtag_id = hashtags.id
+INNER JOIN chirps ON chirps.id = chirp_hashtags.chirp_id
+WHERE chirps.created_at >= sqlc.arg('created_after')::timestamp
+  AND chirps.deleted_at IS NULL AND chirps.hidden_at IS NULL AND chirps.publish_at IS NULL
+GROUP BY hashtags.id
+ORDER BY chirp_count DESC, last_used_at DESC
+LIMIT sqlc.arg('page_limit');
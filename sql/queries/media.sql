@@ -0,0 +1,50 @@
+-- name: CreateMedia :one
+INSERT INTO media (
+  id, user_id, content_type, storage_key, thumbnail_content_type, thumbnail_key,
+  width, height, size_bytes, created_at
+)
+VALUES (
+  $1,
+  $2,
+  $3,
+  $4,
+  $5,
+  $6,
+  $7,
+  $8,
+  $9,
+  $10
+)
+RETURNING *;
+
+-- name: GetVisibleMediaByID :one
+-- attached media is only visible as long as its chirp is
+SELECT media.* FROM media
+LEFT JOIN chirps ON chirps.id = media.chirp_id
+WHERE media.id = $1 AND (
+  (media.attached_at IS NULL AND media.user_id IS NOT NULL)
+  OR (chirps.id IS NOT NULL AND chirps.deleted_at IS NULL AND chirps.hidden_at IS NULL AND chirps.publish_at IS NULL)
+);
+
+-- name: CountAttachableMedia :one
+-- uploads can only be attached once and only by the user who uploaded them
+SELECT COUNT(*) FROM media
+WHERE id = ANY(sqlc.arg('media_ids')::uuid[]) AND user_id = sqlc.arg('user_id')::uuid AND attached_at IS NULL;
+
+-- name: AttachMediaToChirp :execrows
+-- media is shown in the order of media_ids
+UPDATE media
+SET chirp_id = sqlc.arg('chirp_id'), attached_at = sqlc.arg('attached_at')::timestamp,
+  position = array_position(sqlc.arg('media_ids')::uuid[], media.id)
+WHERE id = ANY(sqlc.arg('media_ids')::uuid[]) AND user_id = sqlc.arg('user_id')::uuid AND attached_at IS NULL;
+
+-- name: GetChirpMedia :many
+SELECT * FROM media
+WHERE chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[])
+ORDER BY chirp_id, position;
+
+-- name: PurgeOrphanedMedia :many
+-- media of purged users goes right away, everything else after created_before
+DELETE FROM media
+WHERE chirp_id IS NULL AND (user_id IS NULL OR created_at < sqlc.arg('created_before')::timestamp)
+RETURNING storage_key, thumbnail_key;
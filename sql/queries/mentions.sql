@@ -0,0 +1,23 @@
+-- name: SetChirpMentions :exec
+-- replaces the mentions of a chirp, names that are not the handle of an active
+-- user are skipped. Email addresses are never matched, so mentions can not be
+-- used to find the account of an address.
+WITH removed AS (
+  DELETE FROM mentions
+  WHERE mentions.chirp_id = sqlc.arg('chirp_id')
+)
+INSERT INTO mentions (chirp_id, user_id, start_index, end_index)
+SELECT sqlc.arg('chirp_id'), users.id, names.start_index, names.end_index
+FROM unnest(
+  sqlc.arg('names')::text[],
+  sqlc.arg('start_indexes')::integer[],
+  sqlc.arg('end_indexes')::integer[]
+) AS names(name, start_index, end_index)
+INNER JOIN users ON users.handle = names.name AND users.deleted_at IS NULL;
+
+-- name: GetChirpMentions :many
+SELECT mentions.chirp_id, mentions.user_id, users.handle, mentions.start_index, mentions.end_index
+FROM mentions
+INNER JOIN users ON users.id = mentions.user_id
+WHERE mentions.chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[]) AND users.deleted_at IS NULL
+ORDER BY mentions.chirp_id, mentions.start_index;
@@ -0,0 +1,13 @@
+-- name: GetModerationWords :many
+SELECT word FROM moderation_words
+ORDER BY word ASC;
+
+-- name: CreateModerationFlag :exec
+INSERT INTO chirp_reports (id, chirp_id, reporter_id, reason, created_at)
+VALUES (
+  gen_random_uuid(),
+  $1::uuid,
+  NULL,
+  $2,
+  NOW()
+);
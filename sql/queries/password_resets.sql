@@ -0,0 +1,35 @@
+-- name: CreatePasswordResetToken :execrows
+-- unused tokens sent before stop working, nothing is created while an unused
+-- token created after sent_after exists so the email is not sent again
+WITH recent AS (
+  SELECT 1 FROM password_reset_tokens
+  WHERE password_reset_tokens.user_id = sqlc.arg('user_id') AND password_reset_tokens.used_at IS NULL
+    AND password_reset_tokens.created_at > sqlc.arg('sent_after')::timestamp
+), removed AS (
+  DELETE FROM password_reset_tokens
+  WHERE password_reset_tokens.user_id = sqlc.arg('user_id') AND password_reset_tokens.used_at IS NULL
+    AND NOT EXISTS (SELECT 1 FROM recent)
+)
+INSERT INTO password_reset_tokens (token_hash, user_id, created_at, expires_at)
+SELECT sqlc.arg('token_hash')::text, sqlc.arg('user_id'), sqlc.arg('created_at')::timestamp, sqlc.arg('expires_at')::timestamp
+WHERE NOT EXISTS (SELECT 1 FROM recent);
+
+-- name: ResetPassword :one
+-- uses up the token, sets the new password and revokes all refresh tokens of
+-- the user so sessions on other devices end
+WITH used_token AS (
+  UPDATE password_reset_tokens
+  SET used_at = sqlc.arg('now')::timestamp
+  WHERE token_hash = sqlc.arg('token_hash') AND used_at IS NULL AND expires_at > sqlc.arg('now')::timestamp
+  RETURNING user_id
+), revoked_tokens AS (
+  UPDATE refresh_tokens
+  SET revoked_at = NOW(), updated_at = NOW()
+  FROM used_token
+  WHERE refresh_tokens.user_id = used_token.user_id AND refresh_tokens.revoked_at IS NULL
+)
+UPDATE users
+SET hashed_password = sqlc.arg('hashed_password'), updated_at = NOW()
+FROM used_token
+WHERE users.id = used_token.user_id AND users.deleted_at IS NULL
+RETURNING users.*;
@@ -0,0 +1,32 @@
+-- name: CreatePersonalAccessToken :one
+INSERT INTO personal_access_tokens (id, user_id, name, token_hash, scopes, created_at, expires_at)
+VALUES (
+  gen_random_uuid(),
+  $1,
+  $2,
+  $3,
+  $4,
+  $6,
+  $5
+)
+RETURNING *;
+
+-- name: GetPersonalAccessTokens :many
+SELECT * FROM personal_access_tokens
+WHERE user_id = $1
+ORDER BY created_at DESC;
+
+-- name: UsePersonalAccessToken :one
+-- expired tokens are kept until they are deleted, so users can see them
+UPDATE personal_access_tokens
+SET last_used_at = sqlc.arg('now')::timestamp
+WHERE token_hash = sqlc.arg('token_hash') AND (expires_at IS NULL OR expires_at > sqlc.arg('now')::timestamp)
+RETURNING *;
+
+-- name: DeletePersonalAccessToken :execrows
+DELETE FROM personal_access_tokens
+WHERE id = $1 AND user_id = $2;
+
+-- name: DeleteUserPersonalAccessTokens :exec
+DELETE FROM personal_access_tokens
+WHERE user_id = $1;
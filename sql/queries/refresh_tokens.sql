@@ -1,25 +1,86 @@
 -- name: CreateRefreshToken :one
-INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at)
+INSERT INTO refresh_tokens (token_hash, created_at, updated_at, last_used_at, user_id, expires_at, family_id, user_agent, ip_address)
 VALUES (
   $1,
   NOW(),
   NOW(),
+  NOW(),
   $2,
-  $3
+  $3,
+  $4,
+  $5,
+  $6
 )
 RETURNING *;
 
 -- name: GetRefreshToken :one
 SELECT * FROM refresh_tokens
-WHERE token = $1;
+WHERE token_hash = $1;
+
+-- name: RotateRefreshToken :one
+-- replaces a valid token with a new one of the same family, returns no rows if
+-- the token has already been replaced, revoked or is expired
+WITH rotated AS (
+  UPDATE refresh_tokens
+  SET replaced_at = NOW(), revoked_at = NOW(), updated_at = NOW()
+  WHERE refresh_tokens.token_hash = sqlc.arg('old_token_hash')
+    AND refresh_tokens.replaced_at IS NULL
+    AND refresh_tokens.revoked_at IS NULL
+    AND refresh_tokens.expires_at > sqlc.arg('now')::timestamp
+  RETURNING refresh_tokens.user_id, refresh_tokens.family_id
+)
+INSERT INTO refresh_tokens (token_hash, created_at, updated_at, last_used_at, user_id, expires_at, family_id, user_agent, ip_address)
+SELECT
+  sqlc.arg('new_token_hash')::text,
+  NOW(),
+  NOW(),
+  NOW(),
+  rotated.user_id,
+  sqlc.arg('expires_at')::timestamp,
+  rotated.family_id,
+  sqlc.arg('user_agent')::text,
+  sqlc.arg('ip_address')::text
+FROM rotated
+RETURNING *;
 
--- name: GetUserFromRefreshToken :one
-SELECT U.*
-FROM users U
-INNER JOIN refresh_tokens R ON R.user_id = U.id
-WHERE token = $1;
+-- name: RevokeRefreshTokenFamily :exec
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE family_id = $1 AND revoked_at IS NULL;
 
 -- name: SetRefreshTokenRevokedAt :exec
+-- revokes the whole family of the token, tokens it has been rotated into
+-- included
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE family_id = (
+  SELECT R.family_id FROM refresh_tokens R
+  WHERE R.token_hash = $1
+) AND revoked_at IS NULL;
+
+-- name: GetUserSessions :many
+-- every token family with a token that can still be used is a session, the
+-- session started when the first token of the family was created
+SELECT
+  R.family_id,
+  R.user_agent,
+  R.ip_address,
+  R.last_used_at,
+  R.expires_at,
+  (
+    SELECT MIN(F.created_at) FROM refresh_tokens F
+    WHERE F.family_id = R.family_id
+  )::timestamp AS started_at
+FROM refresh_tokens R
+WHERE R.user_id = sqlc.arg('user_id') AND R.revoked_at IS NULL AND R.expires_at > sqlc.arg('now')::timestamp
+ORDER BY R.last_used_at DESC;
+
+-- name: RevokeUserSession :execrows
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE family_id = $1 AND user_id = $2 AND revoked_at IS NULL;
+
+-- name: RevokeUserSessions :execrows
 UPDATE refresh_tokens
 SET revoked_at = NOW(), updated_at = NOW()
-WHERE token = $1;
+WHERE user_id = $1 AND revoked_at IS NULL;
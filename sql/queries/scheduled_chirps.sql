@@ -0,0 +1,22 @@
+-- name: GetScheduledChirps :many
+SELECT * FROM chirps
+WHERE user_id = $1 AND publish_at IS NOT NULL AND deleted_at IS NULL
+ORDER BY publish_at ASC, id ASC;
+
+-- name: DeleteScheduledChirp :execrows
+DELETE FROM chirps
+WHERE id = $1 AND user_id = $2 AND publish_at IS NOT NULL;
+
+-- name: GetNextPublishAt :one
+SELECT publish_at FROM chirps
+WHERE publish_at IS NOT NULL AND deleted_at IS NULL
+ORDER BY publish_at ASC
+LIMIT 1;
+
+-- name: PublishDueChirps :many
+-- published chirps are dated to when they were scheduled for so they show up
+-- in the right place in paginated lists
+UPDATE chirps
+SET created_at = publish_at, updated_at = sqlc.arg('now')::timestamp, publish_at = NULL
+WHERE publish_at <= sqlc.arg('now')::timestamp AND deleted_at IS NULL
+RETURNING *;
@@ -0,0 +1,42 @@
+-- name: SearchChirpsByRank :many
+-- The cursor carries the rank of the last row as a real, so it compares equal
+-- to the rank computed here for the same row.
+SELECT id, body, created_at, updated_at, user_id, deleted_at, hidden_at,
+  reply_to_id, rechirp_of_id, quote_of_id, publish_at, rank
+FROM (
+  SELECT id, body, created_at, updated_at, user_id, deleted_at, hidden_at,
+    reply_to_id, rechirp_of_id, quote_of_id, publish_at,
+    ts_rank(to_tsvector('english', body), to_tsquery('english', sqlc.arg('query'))) AS rank
+  FROM chirps
+  WHERE deleted_at IS NULL AND hidden_at IS NULL AND publish_at IS NULL
+    AND to_tsvector('english', body) @@ to_tsquery('english', sqlc.arg('query'))
+    AND (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id')::uuid)
+) AS ranked
+WHERE sqlc.narg('cursor_rank')::real IS NULL
+  OR (rank, id) < (sqlc.narg('cursor_rank')::real, sqlc.narg('cursor_id')::uuid)
+ORDER BY rank DESC, id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: SearchChirpsAsc :many
+SELECT * FROM chirps
+WHERE deleted_at IS NULL AND hidden_at IS NULL AND publish_at IS NULL
+  AND to_tsvector('english', body) @@ to_tsquery('english', sqlc.arg('query'))
+  AND (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id')::uuid)
+  AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+  )
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('page_limit');
+
+-- name: SearchChirpsDesc :many
+SELECT * FROM chirps
+WHERE deleted_at IS NULL AND hidden_at IS NULL AND publish_at IS NULL
+  AND to_tsvector('english', body) @@ to_tsquery('english', sqlc.arg('query'))
+  AND (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id')::uuid)
+  AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+  )
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('page_limit');
@@ -0,0 +1,96 @@
+-- name: StartTOTPSetup :one
+-- starting over replaces the secret of a setup that was never confirmed, but
+-- not the one of an enabled 2FA
+INSERT INTO user_totp (user_id, secret, created_at)
+VALUES (
+  $1,
+  $2,
+  NOW()
+)
+ON CONFLICT (user_id) DO UPDATE
+SET secret = EXCLUDED.secret, created_at = NOW()
+WHERE user_totp.enabled_at IS NULL
+RETURNING *;
+
+-- name: GetUserTOTP :one
+SELECT * FROM user_totp
+WHERE user_id = $1;
+
+-- name: IsTwoFactorEnabled :one
+SELECT EXISTS(
+  SELECT 1 FROM user_totp
+  WHERE user_id = $1 AND enabled_at IS NOT NULL
+);
+
+-- name: UseTOTPStep :one
+-- accepts a code only if it is newer than the last one used, enables 2FA if it
+-- was still being set up
+UPDATE user_totp
+SET last_used_step = sqlc.arg('step')::bigint, enabled_at = COALESCE(enabled_at, NOW())
+WHERE user_id = sqlc.arg('user_id') AND (last_used_step IS NULL OR last_used_step < sqlc.arg('step')::bigint)
+RETURNING *;
+
+-- name: DisableTwoFactor :exec
+WITH removed_codes AS (
+  DELETE FROM recovery_codes
+  WHERE recovery_codes.user_id = $1
+), removed_challenges AS (
+  DELETE FROM two_factor_challenges
+  WHERE two_factor_challenges.user_id = $1
+)
+DELETE FROM user_totp
+WHERE user_totp.user_id = $1;
+
+-- name: AttemptSecondFactor :one
+-- counts an attempt before the code is checked, so parallel requests can not
+-- send more codes than allowed. The attempt that uses up max_attempts locks the
+-- user out until lock_until, ResetSecondFactorAttempts clears it on success.
+UPDATE user_totp
+SET
+  failed_attempts = CASE WHEN failed_attempts + 1 >= sqlc.arg('max_attempts')::int THEN 0 ELSE failed_attempts + 1 END,
+  locked_until = CASE WHEN failed_attempts + 1 >= sqlc.arg('max_attempts')::int THEN sqlc.arg('lock_until')::timestamp ELSE locked_until END
+WHERE user_id = sqlc.arg('user_id') AND enabled_at IS NOT NULL
+  AND (locked_until IS NULL OR locked_until <= sqlc.arg('now')::timestamp)
+RETURNING *;
+
+-- name: ResetSecondFactorAttempts :exec
+UPDATE user_totp
+SET failed_attempts = 0, locked_until = NULL
+WHERE user_id = $1;
+
+-- name: ReplaceRecoveryCodes :exec
+-- codes handed out before stop working
+WITH removed AS (
+  DELETE FROM recovery_codes
+  WHERE recovery_codes.user_id = sqlc.arg('user_id')
+)
+INSERT INTO recovery_codes (user_id, code_hash, created_at)
+SELECT sqlc.arg('user_id'), codes.code_hash, NOW()
+FROM unnest(sqlc.arg('code_hashes')::text[]) AS codes(code_hash);
+
+-- name: UseRecoveryCode :execrows
+UPDATE recovery_codes
+SET used_at = NOW()
+WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL;
+
+-- name: CreateTwoFactorChallenge :exec
+INSERT INTO two_factor_challenges (token_hash, user_id, created_at, expires_at)
+VALUES (
+  $1,
+  $2,
+  NOW(),
+  $3
+);
+
+-- name: AttemptTwoFactorChallenge :one
+-- counts every attempt so codes can not be guessed with a single challenge
+UPDATE two_factor_challenges
+SET attempts = attempts + 1
+WHERE token_hash = sqlc.arg('token_hash') AND expires_at > sqlc.arg('now')::timestamp
+  AND attempts < sqlc.arg('max_attempts')::int
+RETURNING user_id;
+
+-- name: DeleteTwoFactorChallenge :exec
+DELETE FROM two_factor_challenges
+WHERE token_hash = $1;
+
@@ -0,0 +1,69 @@
+-- name: CreateOIDCLoginState :one
+-- logins that were never finished are cleaned up along the way
+WITH expired AS (
+  DELETE FROM oidc_login_states
+  WHERE oidc_login_states.expires_at < sqlc.arg('now')::timestamp
+)
+INSERT INTO oidc_login_states (state_hash, nonce, code_verifier, created_at, expires_at)
+VALUES (
+  sqlc.arg('state_hash'),
+  sqlc.arg('nonce'),
+  sqlc.arg('code_verifier'),
+  sqlc.arg('now')::timestamp,
+  sqlc.arg('expires_at')
+)
+RETURNING *;
+
+-- name: UseOIDCLoginState :one
+-- a state can only be used once
+DELETE FROM oidc_login_states
+WHERE state_hash = sqlc.arg('state_hash') AND expires_at > sqlc.arg('now')::timestamp
+RETURNING *;
+
+-- name: GetUserByIdentity :one
+-- deleted users are returned as well, so their identity is not linked again
+SELECT users.* FROM users
+JOIN user_identities ON user_identities.user_id = users.id
+WHERE user_identities.issuer = $1 AND user_identities.subject = $2;
+
+-- name: CreateUserIdentity :one
+INSERT INTO user_identities (id, user_id, issuer, subject, email, created_at, last_login_at)
+VALUES (
+  gen_random_uuid(),
+  $1,
+  $2,
+  $3,
+  $4,
+  NOW(),
+  NOW()
+)
+RETURNING *;
+
+-- name: TouchUserIdentity :exec
+UPDATE user_identities
+SET email = $3, last_login_at = NOW()
+WHERE issuer = $1 AND subject = $2;
+
+-- name: CreateOIDCUser :one
+-- creates a user along with the identity it logged in with. Users created by a
+-- provider have no password, they can set one with a password reset
+WITH new_user AS (
+  INSERT INTO users (id, created_at, updated_at, email, hashed_password, handle, display_name, bio, email_verified_at)
+  VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    sqlc.arg('email'),
+    '',
+    sqlc.arg('handle'),
+    sqlc.arg('display_name'),
+    '',
+    CASE WHEN sqlc.arg('email_verified')::boolean THEN NOW() END
+  )
+  RETURNING *
+), new_identity AS (
+  INSERT INTO user_identities (id, user_id, issuer, subject, email, created_at, last_login_at)
+  SELECT gen_random_uuid(), new_user.id, sqlc.arg('issuer'), sqlc.arg('subject'), new_user.email, NOW(), NOW()
+  FROM new_user
+)
+SELECT * FROM new_user;
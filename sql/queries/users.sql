@@ -1,29 +1,116 @@
 -- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, handle, display_name, bio)
 VALUES (
   gen_random_uuid(),
   NOW(),
   NOW(),
   $1,
-  $2
+  $2,
+  $3,
+  $4,
+  $5
 )
 RETURNING *;
 
 -- name: GetUserByEmail :one
 SELECT * FROM users
-WHERE email = $1;
+WHERE email = $1 AND deleted_at IS NULL;
 
 -- name: GetUserById :one
 SELECT * FROM users
-WHERE id = $1;
+WHERE id = $1 AND deleted_at IS NULL;
 
 -- name: UpdateUserCredentialsById :one
+-- changing the email address makes it unverified again
 UPDATE users
-SET email = $2, hashed_password = $3, updated_at = NOW()
+SET email = $2, hashed_password = $3, updated_at = NOW(),
+  email_verified_at = CASE WHEN users.email = $2 THEN users.email_verified_at END
 WHERE id = $1
 RETURNING *;
 
+-- name: SetUserPasswordHash :exec
+-- only replaces the hash that was verified, in case the password was changed
+-- in the meantime
+UPDATE users
+SET hashed_password = sqlc.arg('hashed_password')
+WHERE id = sqlc.arg('id') AND hashed_password = sqlc.arg('old_hash');
+
 -- name: SetUserPremium :exec
 UPDATE users
 SET is_premium = true
 WHERE id = $1;
+
+-- name: SoftDeleteUserByID :exec
+-- the users chirps are tombstoned with the same timestamp so restoring the
+-- user brings back exactly the chirps deleted along with them
+WITH deleted_user AS (
+  UPDATE users
+  SET deleted_at = sqlc.arg('deleted_at')::timestamp, updated_at = NOW()
+  WHERE users.id = sqlc.arg('id') AND users.deleted_at IS NULL
+  RETURNING users.id, users.deleted_at
+)
+UPDATE chirps
+SET deleted_at = deleted_user.deleted_at
+FROM deleted_user
+WHERE chirps.user_id = deleted_user.id AND chirps.deleted_at IS NULL;
+
+-- name: GetDeletedUserByEmail :one
+SELECT * FROM users
+WHERE email = $1 AND deleted_at IS NOT NULL;
+
+-- name: RestoreUserByID :one
+WITH deleted_user AS (
+  SELECT users.id, users.deleted_at FROM users
+  WHERE users.id = $1 AND users.deleted_at >= sqlc.arg('deleted_after')::timestamp
+), restored_chirps AS (
+  UPDATE chirps
+  SET deleted_at = NULL
+  FROM deleted_user
+  WHERE chirps.user_id = deleted_user.id AND chirps.deleted_at = deleted_user.deleted_at
+)
+UPDATE users
+SET deleted_at = NULL, updated_at = NOW()
+FROM deleted_user
+WHERE users.id = deleted_user.id
+RETURNING users.*;
+
+-- name: PurgeDeletedUsers :execrows
+DELETE FROM users
+WHERE deleted_at < sqlc.arg('deleted_before')::timestamp;
+
+-- name: LockAdmins :many
+-- role changes wait for each other, so two admins cannot demote each other
+-- at once and leave no admin behind
+SELECT id FROM users
+WHERE role = 'admin' AND deleted_at IS NULL
+ORDER BY id
+FOR UPDATE;
+
+-- name: SetUserRole :one
+UPDATE users
+SET role = $2, updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING *;
+
+-- name: HandleExists :one
+-- handles of deleted users stay taken so they can still be restored
+SELECT EXISTS(
+  SELECT 1 FROM users
+  WHERE handle = $1
+);
+
+-- name: UpdateUserProfile :one
+UPDATE users
+SET handle = $2, display_name = $3, bio = $4, updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING *;
+
+-- name: GetUserProfileByHandle :one
+SELECT users.id, users.handle, users.display_name, users.bio, users.created_at,
+  (
+    SELECT COUNT(*) FROM chirps
+    WHERE chirps.user_id = users.id AND chirps.rechirp_of_id IS NULL
+      AND chirps.deleted_at IS NULL AND chirps.hidden_at IS NULL AND chirps.publish_at IS NULL
+  ) AS chirp_count
+FROM users
+WHERE users.handle = $1 AND users.deleted_at IS NULL;
@@ -0,0 +1,21 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN deleted_at TIMESTAMP DEFAULT NULL;
+
+ALTER TABLE chirps
+ADD COLUMN deleted_at TIMESTAMP DEFAULT NULL;
+
+-- only tombstones are looked up by deleted_at, by the restore endpoints and
+-- the purge job
+CREATE INDEX users_deleted_at_idx ON users (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX chirps_deleted_at_idx ON chirps (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- +goose Down
+DROP INDEX chirps_deleted_at_idx;
+DROP INDEX users_deleted_at_idx;
+
+ALTER TABLE chirps
+DROP COLUMN deleted_at;
+
+ALTER TABLE users
+DROP COLUMN deleted_at;
@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE moderation_words (
+  word TEXT PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE moderation_flags (
+  id uuid PRIMARY KEY,
+  chirp_id uuid NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+  reasons TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX moderation_flags_chirp_id_idx ON moderation_flags (chirp_id);
+
+-- +goose Down
+DROP TABLE moderation_flags;
+DROP TABLE moderation_words;
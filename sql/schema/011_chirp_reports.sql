@@ -0,0 +1,50 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN role TEXT NOT NULL
+DEFAULT 'user'
+CONSTRAINT users_role_check CHECK (role IN ('user', 'admin'));
+
+ALTER TABLE chirps
+ADD COLUMN hidden_at TIMESTAMP DEFAULT NULL;
+
+-- reports without a reporter were flagged by the moderation pipeline
+CREATE TABLE chirp_reports (
+  id uuid PRIMARY KEY,
+  chirp_id uuid NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+  reporter_id uuid REFERENCES users(id) ON DELETE CASCADE,
+  reason TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  resolved_at TIMESTAMP DEFAULT NULL,
+  resolved_by uuid REFERENCES users(id) ON DELETE SET NULL,
+  resolution TEXT DEFAULT NULL
+);
+
+CREATE UNIQUE INDEX chirp_reports_open_reporter_idx ON chirp_reports (chirp_id, reporter_id) WHERE resolved_at IS NULL;
+CREATE INDEX chirp_reports_open_idx ON chirp_reports (created_at) WHERE resolved_at IS NULL;
+
+INSERT INTO chirp_reports (id, chirp_id, reporter_id, reason, created_at)
+SELECT id, chirp_id, NULL, reasons, created_at FROM moderation_flags;
+
+DROP TABLE moderation_flags;
+
+-- +goose Down
+CREATE TABLE moderation_flags (
+  id uuid PRIMARY KEY,
+  chirp_id uuid NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+  reasons TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX moderation_flags_chirp_id_idx ON moderation_flags (chirp_id);
+
+INSERT INTO moderation_flags (id, chirp_id, reasons, created_at)
+SELECT id, chirp_id, reason, created_at FROM chirp_reports
+WHERE reporter_id IS NULL AND resolved_at IS NULL;
+
+DROP TABLE chirp_reports;
+
+ALTER TABLE chirps
+DROP COLUMN hidden_at;
+
+ALTER TABLE users
+DROP COLUMN role;
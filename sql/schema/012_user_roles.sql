@@ -0,0 +1,17 @@
+-- +goose Up
+ALTER TABLE users
+DROP CONSTRAINT users_role_check;
+
+ALTER TABLE users
+ADD CONSTRAINT users_role_check CHECK (role IN ('user', 'moderator', 'admin'));
+
+-- +goose Down
+UPDATE users
+SET role = 'user'
+WHERE role = 'moderator';
+
+ALTER TABLE users
+DROP CONSTRAINT users_role_check;
+
+ALTER TABLE users
+ADD CONSTRAINT users_role_check CHECK (role IN ('user', 'admin'));
@@ -0,0 +1,13 @@
+-- +goose Up
+-- replies of purged chirps stay around as top level chirps, while the
+-- parent is only soft deleted the reference is kept so it can be restored
+ALTER TABLE chirps
+ADD COLUMN reply_to_id uuid REFERENCES chirps(id) ON DELETE SET NULL;
+
+CREATE INDEX chirps_reply_to_id_created_at_id_idx ON chirps (reply_to_id, created_at, id) WHERE reply_to_id IS NOT NULL;
+
+-- +goose Down
+DROP INDEX chirps_reply_to_id_created_at_id_idx;
+
+ALTER TABLE chirps
+DROP COLUMN reply_to_id;
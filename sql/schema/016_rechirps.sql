@@ -0,0 +1,19 @@
+-- +goose Up
+-- plain rechirps have an empty body and go away with the original, quotes
+-- keep their own text if the quoted chirp is purged
+ALTER TABLE chirps
+ADD COLUMN rechirp_of_id uuid REFERENCES chirps(id) ON DELETE CASCADE;
+
+ALTER TABLE chirps
+ADD COLUMN quote_of_id uuid REFERENCES chirps(id) ON DELETE SET NULL;
+
+CREATE UNIQUE INDEX chirps_user_id_rechirp_of_id_idx ON chirps (user_id, rechirp_of_id) WHERE rechirp_of_id IS NOT NULL AND deleted_at IS NULL;
+
+-- +goose Down
+DROP INDEX chirps_user_id_rechirp_of_id_idx;
+
+ALTER TABLE chirps
+DROP COLUMN quote_of_id;
+
+ALTER TABLE chirps
+DROP COLUMN rechirp_of_id;
@@ -0,0 +1,30 @@
+-- +goose Up
+CREATE TABLE hashtags (
+  id uuid PRIMARY KEY,
+  tag TEXT UNIQUE NOT NULL,
+  created_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE chirp_hashtags (
+  chirp_id uuid NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+  hashtag_id uuid NOT NULL REFERENCES hashtags(id) ON DELETE CASCADE,
+  PRIMARY KEY (chirp_id, hashtag_id)
+);
+
+CREATE INDEX chirp_hashtags_hashtag_id_idx ON chirp_hashtags (hashtag_id);
+
+-- start_index and end_index are character offsets into the chirp body
+CREATE TABLE mentions (
+  chirp_id uuid NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+  user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  start_index INTEGER NOT NULL,
+  end_index INTEGER NOT NULL,
+  PRIMARY KEY (chirp_id, start_index)
+);
+
+CREATE INDEX mentions_user_id_idx ON mentions (user_id);
+
+-- +goose Down
+DROP TABLE mentions;
+DROP TABLE chirp_hashtags;
+DROP TABLE hashtags;
@@ -0,0 +1,20 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN handle TEXT,
+ADD COLUMN display_name TEXT NOT NULL DEFAULT '',
+ADD COLUMN bio TEXT NOT NULL DEFAULT '';
+
+-- existing users get a handle derived from their id, they can pick a new one
+UPDATE users
+SET handle = 'user_' || substr(replace(id::text, '-', ''), 1, 12);
+
+ALTER TABLE users
+ALTER COLUMN handle SET NOT NULL,
+ADD CONSTRAINT users_handle_key UNIQUE (handle),
+ADD CONSTRAINT users_handle_check CHECK (handle ~ '^[a-z0-9_]{3,30}$');
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN bio,
+DROP COLUMN display_name,
+DROP COLUMN handle;
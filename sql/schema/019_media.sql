@@ -0,0 +1,23 @@
+-- +goose Up
+CREATE TABLE media (
+  id uuid PRIMARY KEY,
+  user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  -- NULL until the upload is attached to a chirp, uploads that are never
+  -- attached or whose chirp has been purged are cleaned up by the purge job
+  chirp_id uuid REFERENCES chirps(id) ON DELETE SET NULL,
+  attached_at TIMESTAMP,
+  position INTEGER NOT NULL DEFAULT 0,
+  content_type TEXT NOT NULL,
+  storage_key TEXT NOT NULL,
+  thumbnail_content_type TEXT NOT NULL,
+  thumbnail_key TEXT NOT NULL,
+  width INTEGER NOT NULL,
+  height INTEGER NOT NULL,
+  size_bytes BIGINT NOT NULL,
+  created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX media_chirp_id_idx ON media (chirp_id);
+
+-- +goose Down
+DROP TABLE media;
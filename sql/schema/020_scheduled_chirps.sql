@@ -0,0 +1,15 @@
+-- +goose Up
+-- chirps with a publish_at are pending and hidden until the scheduler
+-- publishes them by clearing it
+ALTER TABLE chirps
+ADD COLUMN publish_at TIMESTAMP;
+
+CREATE INDEX chirps_publish_at_idx ON chirps (publish_at)
+WHERE publish_at IS NOT NULL;
+
+-- +goose Down
+DELETE FROM chirps
+WHERE publish_at IS NOT NULL;
+
+ALTER TABLE chirps
+DROP COLUMN publish_at;
@@ -0,0 +1,25 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN email_verified_at TIMESTAMP;
+
+-- accounts created before verification existed are trusted
+UPDATE users
+SET email_verified_at = created_at;
+
+-- tokens are stored hashed, email is the address the token was sent to so a
+-- token stops working when the user changes their email
+CREATE TABLE email_verification_tokens (
+  token_hash TEXT PRIMARY KEY,
+  user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  email TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX email_verification_tokens_user_id_idx ON email_verification_tokens (user_id);
+
+-- +goose Down
+DROP TABLE email_verification_tokens;
+
+ALTER TABLE users
+DROP COLUMN email_verified_at;
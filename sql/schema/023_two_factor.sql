@@ -0,0 +1,37 @@
+-- +goose Up
+-- the secret is kept until 2FA is disabled, enabled_at stays NULL until the
+-- user confirmed the setup with a code. last_used_step is the TOTP time step
+-- of the last accepted code so a code can not be used twice.
+CREATE TABLE user_totp (
+  user_id uuid PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  secret TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  enabled_at TIMESTAMP,
+  last_used_step BIGINT
+);
+
+-- codes are stored hashed and can only be used once
+CREATE TABLE recovery_codes (
+  user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  code_hash TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  used_at TIMESTAMP,
+  PRIMARY KEY (user_id, code_hash)
+);
+
+-- handed out by /api/login when the password was correct, the second step
+-- of the login has to send it along with a code
+CREATE TABLE two_factor_challenges (
+  token_hash TEXT PRIMARY KEY,
+  user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  created_at TIMESTAMP NOT NULL,
+  expires_at TIMESTAMP NOT NULL,
+  attempts INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX two_factor_challenges_user_id_idx ON two_factor_challenges (user_id);
+
+-- +goose Down
+DROP TABLE two_factor_challenges;
+DROP TABLE recovery_codes;
+DROP TABLE user_totp;
@@ -0,0 +1,22 @@
+-- +goose Up
+-- every login starts a new family, refreshing replaces the token with a new
+-- one of the same family. replaced_at is set on rotated tokens so presenting
+-- one of them again is detected as reuse.
+ALTER TABLE refresh_tokens
+ADD COLUMN family_id uuid,
+ADD COLUMN replaced_at TIMESTAMP;
+
+UPDATE refresh_tokens
+SET family_id = gen_random_uuid();
+
+ALTER TABLE refresh_tokens
+ALTER COLUMN family_id SET NOT NULL;
+
+CREATE INDEX refresh_tokens_family_id_idx ON refresh_tokens (family_id);
+
+-- +goose Down
+DROP INDEX refresh_tokens_family_id_idx;
+
+ALTER TABLE refresh_tokens
+DROP COLUMN replaced_at,
+DROP COLUMN family_id;
@@ -0,0 +1,34 @@
+-- +goose Up
+-- refresh tokens are stored as their SHA-256 like the other tokens. A session
+-- is a token family, the token of it that has not been revoked yet carries the
+-- device it was last used from.
+ALTER TABLE refresh_tokens
+ADD COLUMN token_hash TEXT,
+ADD COLUMN user_agent TEXT NOT NULL DEFAULT '',
+ADD COLUMN ip_address TEXT NOT NULL DEFAULT '',
+ADD COLUMN last_used_at TIMESTAMP;
+
+UPDATE refresh_tokens
+SET token_hash = encode(sha256(convert_to(token, 'UTF8')), 'hex'), last_used_at = created_at;
+
+ALTER TABLE refresh_tokens
+DROP CONSTRAINT refresh_tokens_pkey,
+DROP COLUMN token,
+ALTER COLUMN last_used_at SET NOT NULL,
+ADD PRIMARY KEY (token_hash);
+
+CREATE INDEX refresh_tokens_user_id_idx ON refresh_tokens (user_id);
+
+-- +goose Down
+-- the plain tokens are gone, so all sessions end
+DROP INDEX refresh_tokens_user_id_idx;
+
+DELETE FROM refresh_tokens;
+
+ALTER TABLE refresh_tokens
+DROP CONSTRAINT refresh_tokens_pkey,
+DROP COLUMN last_used_at,
+DROP COLUMN ip_address,
+DROP COLUMN user_agent,
+ADD COLUMN token TEXT PRIMARY KEY,
+DROP COLUMN token_hash;
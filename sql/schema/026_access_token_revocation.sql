@@ -0,0 +1,23 @@
+-- +goose Up
+-- single access tokens revoked by their jti, kept until they expire
+CREATE TABLE revoked_access_tokens (
+  jti TEXT PRIMARY KEY,
+  user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  revoked_at TIMESTAMP NOT NULL,
+  expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX revoked_access_tokens_revoked_at_idx ON revoked_access_tokens (revoked_at);
+
+-- all access tokens of a user issued before revoked_before are revoked
+CREATE TABLE access_token_cutoffs (
+  user_id uuid PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  revoked_before TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX access_token_cutoffs_updated_at_idx ON access_token_cutoffs (updated_at);
+
+-- +goose Down
+DROP TABLE access_token_cutoffs;
+DROP TABLE revoked_access_tokens;
@@ -0,0 +1,27 @@
+-- +goose Up
+-- accounts at OpenID Connect providers users log in with
+CREATE TABLE user_identities (
+  id uuid PRIMARY KEY,
+  user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  issuer TEXT NOT NULL,
+  subject TEXT NOT NULL,
+  email TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  last_login_at TIMESTAMP NOT NULL,
+  UNIQUE (issuer, subject)
+);
+
+CREATE INDEX user_identities_user_id_idx ON user_identities (user_id);
+
+-- logins started at a provider, until it redirects back
+CREATE TABLE oidc_login_states (
+  state_hash TEXT PRIMARY KEY,
+  nonce TEXT NOT NULL,
+  code_verifier TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  expires_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE oidc_login_states;
+DROP TABLE user_identities;
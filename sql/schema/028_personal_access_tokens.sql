@@ -0,0 +1,17 @@
+-- +goose Up
+-- long-lived tokens for scripts and bots, limited to their scopes
+CREATE TABLE personal_access_tokens (
+  id uuid PRIMARY KEY,
+  user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  name TEXT NOT NULL,
+  token_hash TEXT UNIQUE NOT NULL,
+  scopes TEXT[] NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  expires_at TIMESTAMP,
+  last_used_at TIMESTAMP
+);
+
+CREATE INDEX personal_access_tokens_user_id_idx ON personal_access_tokens (user_id);
+
+-- +goose Down
+DROP TABLE personal_access_tokens;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- wrong second factors are counted per user across challenges, once too many
+-- were sent the user is locked out of the second step until locked_until
+ALTER TABLE user_totp
+ADD COLUMN failed_attempts INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN locked_until TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE user_totp
+DROP COLUMN locked_until,
+DROP COLUMN failed_attempts;
@@ -0,0 +1,14 @@
+-- +goose Up
+-- media of purged users is kept as an orphan so the purge job deletes its files
+-- from storage, cascading would only remove the rows
+ALTER TABLE media
+ALTER COLUMN user_id DROP NOT NULL,
+DROP CONSTRAINT media_user_id_fkey,
+ADD CONSTRAINT media_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL;
+
+-- +goose Down
+DELETE FROM media WHERE user_id IS NULL;
+ALTER TABLE media
+DROP CONSTRAINT media_user_id_fkey,
+ADD CONSTRAINT media_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+ALTER COLUMN user_id SET NOT NULL;
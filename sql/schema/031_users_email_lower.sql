@@ -0,0 +1,6 @@
+-- +goose Up
+-- mentions match email addresses ignoring case
+CREATE INDEX users_email_lower_idx ON users (lower(email));
+
+-- +goose Down
+DROP INDEX users_email_lower_idx;
@@ -0,0 +1,14 @@
+-- +goose Up
+-- resolved reports are kept as a record of the moderation when their chirp is
+-- deleted for good
+ALTER TABLE chirp_reports
+ALTER COLUMN chirp_id DROP NOT NULL,
+DROP CONSTRAINT chirp_reports_chirp_id_fkey,
+ADD CONSTRAINT chirp_reports_chirp_id_fkey FOREIGN KEY (chirp_id) REFERENCES chirps(id) ON DELETE SET NULL;
+
+-- +goose Down
+DELETE FROM chirp_reports WHERE chirp_id IS NULL;
+ALTER TABLE chirp_reports
+DROP CONSTRAINT chirp_reports_chirp_id_fkey,
+ADD CONSTRAINT chirp_reports_chirp_id_fkey FOREIGN KEY (chirp_id) REFERENCES chirps(id) ON DELETE CASCADE,
+ALTER COLUMN chirp_id SET NOT NULL;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- mentions only resolve handles, so email addresses can not be matched to
+-- accounts. Mentions stored for an email address are removed.
+DELETE FROM mentions
+USING chirps, users
+WHERE mentions.chirp_id = chirps.id AND mentions.user_id = users.id
+  AND lower(substr(chirps.body, mentions.start_index + 2, mentions.end_index - mentions.start_index - 1)) <> users.handle;
+
+DROP INDEX users_email_lower_idx;
+
+-- +goose Down
+CREATE INDEX users_email_lower_idx ON users (lower(email));